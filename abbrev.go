@@ -0,0 +1,190 @@
+package trie // import "kkn.fi/trie"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoMatch is returned when no key starts with an abbreviation.
+var ErrNoMatch = errors.New("trie: no key matches abbreviation")
+
+// AmbiguityError is returned when an abbreviation is the prefix of more than
+// one key and none of them is the abbreviation itself.
+type AmbiguityError struct {
+	Abbrev     string   // the ambiguous abbreviation
+	Candidates []string // the keys that start with Abbrev
+}
+
+func (e *AmbiguityError) Error() string {
+	return fmt.Sprintf("trie: abbreviation %q is ambiguous: %s", e.Abbrev, strings.Join(e.Candidates, ", "))
+}
+
+// LongestCommonPrefix returns the longest string that all of the keys that
+// start with prefix start with, for example to complete prefix in a command
+// line. It is prefix followed by the characters shared by the keys, or an
+// empty string if no key starts with prefix.
+func (t *Trie) LongestCommonPrefix(prefix string) string {
+	x := t.get(t.root, t.normalized(prefix))
+	if x == nil {
+		return ""
+	}
+	a := t.Alphabet()
+	var chars []rune
+	for !x.isString {
+		n, c := degree(x.next)
+		if n != 1 {
+			break
+		}
+		chars = append(chars, a.ToChar(c))
+		x = x.next[c]
+	}
+	return prefix + string(chars)
+}
+
+// ShortestUniquePrefix returns the shortest prefix of key that no other key
+// in the set starts with, or key itself if it is a prefix of other keys. It
+// returns false if key is not in the set. The prefix is in normalized form.
+func (t *Trie) ShortestUniquePrefix(key string) (string, bool) {
+	chars := []rune(t.normalized(key))
+	a := t.Alphabet()
+	x := t.root
+	d := 1
+	for i, c := range chars {
+		if x == nil {
+			return "", false
+		}
+		// other keys go through x, so the prefix must be longer
+		if n, _ := degree(x.next); x.isString || n > 1 {
+			d = i + 1
+		}
+		x = t.next(a, x, c)
+	}
+	if x == nil || !x.isString {
+		return "", false
+	}
+	if n, _ := degree(x.next); n > 0 {
+		d = len(chars)
+	}
+	return string(chars[:d]), true
+}
+
+// Resolve returns the key that abbrev abbreviates: abbrev itself if it is a
+// key, or otherwise the only key that starts with abbrev. It returns an error
+// wrapping ErrNoMatch if no key starts with abbrev, and an *AmbiguityError if
+// many keys do.
+func (t *Trie) Resolve(abbrev string) (string, error) {
+	norm := t.normalized(abbrev)
+	x := t.get(t.root, norm)
+	if x == nil {
+		return "", fmt.Errorf("%w: %q", ErrNoMatch, abbrev)
+	}
+	chars := []rune(norm)
+	if x.isString {
+		return x.spelling(chars), nil
+	}
+	a := t.Alphabet()
+	for !x.isString {
+		n, c := degree(x.next)
+		if n != 1 {
+			break
+		}
+		chars = append(chars, a.ToChar(c))
+		x = x.next[c]
+	}
+	if n, _ := degree(x.next); x.isString && n == 0 {
+		return x.spelling(chars), nil
+	}
+	return "", &AmbiguityError{Abbrev: abbrev, Candidates: t.KeysWithPrefix(abbrev)}
+}
+
+// LongestCommonPrefix returns the longest string that all of the keys that
+// start with prefix start with, for example to complete prefix in a command
+// line. It is prefix followed by the characters shared by the keys, or an
+// empty string if no key starts with prefix.
+func (t *SymbolTable) LongestCommonPrefix(prefix string) string {
+	x := t.get(t.root, t.normalized(prefix))
+	if x == nil {
+		return ""
+	}
+	a := t.Alphabet()
+	var chars []rune
+	for x.value == nil {
+		n, c := degree(x.next)
+		if n != 1 {
+			break
+		}
+		chars = append(chars, a.ToChar(c))
+		x = x.next[c]
+	}
+	return prefix + string(chars)
+}
+
+// ShortestUniquePrefix returns the shortest prefix of key that no other key
+// in the symbol table starts with, or key itself if it is a prefix of other
+// keys. It returns false if key is not in the symbol table. The prefix is in
+// normalized form.
+func (t *SymbolTable) ShortestUniquePrefix(key string) (string, bool) {
+	chars := []rune(t.normalized(key))
+	a := t.Alphabet()
+	x := t.root
+	d := 1
+	for i, c := range chars {
+		if x == nil {
+			return "", false
+		}
+		// other keys go through x, so the prefix must be longer
+		if n, _ := degree(x.next); x.value != nil || n > 1 {
+			d = i + 1
+		}
+		x = t.next(a, x, c)
+	}
+	if x == nil || x.value == nil {
+		return "", false
+	}
+	if n, _ := degree(x.next); n > 0 {
+		d = len(chars)
+	}
+	return string(chars[:d]), true
+}
+
+// Resolve returns the key that abbrev abbreviates and its value: abbrev
+// itself if it is a key, or otherwise the only key that starts with abbrev.
+// It returns an error wrapping ErrNoMatch if no key starts with abbrev, and
+// an *AmbiguityError if many keys do.
+func (t *SymbolTable) Resolve(abbrev string) (string, interface{}, error) {
+	norm := t.normalized(abbrev)
+	x := t.get(t.root, norm)
+	if x == nil {
+		return "", nil, fmt.Errorf("%w: %q", ErrNoMatch, abbrev)
+	}
+	chars := []rune(norm)
+	if x.value != nil {
+		return x.spelling(chars), x.value, nil
+	}
+	a := t.Alphabet()
+	for x.value == nil {
+		n, c := degree(x.next)
+		if n != 1 {
+			break
+		}
+		chars = append(chars, a.ToChar(c))
+		x = x.next[c]
+	}
+	if n, _ := degree(x.next); x.value != nil && n == 0 {
+		return x.spelling(chars), x.value, nil
+	}
+	return "", nil, &AmbiguityError{Abbrev: abbrev, Candidates: t.KeysWithPrefix(abbrev)}
+}
+
+// degree returns the number of children in next and the index of the last
+// one.
+func degree[N any](next []*N) (n, last int) {
+	last = -1
+	for i, x := range next {
+		if x != nil {
+			n, last = n+1, i
+		}
+	}
+	return n, last
+}
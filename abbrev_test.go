@@ -0,0 +1,122 @@
+package trie_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+var commands = []string{"go", "gofmt", "git", "grep", "gzip", "make"}
+
+func TestTrieLongestCommonPrefix(t *testing.T) {
+	tr := trie.New()
+	for _, c := range commands {
+		tr.Add(c)
+	}
+	tr.Add("makefile-lint")
+	tr.Add("makefile-format")
+	td := []struct {
+		prefix   string
+		expected string
+	}{
+		{"g", "g"},
+		{"gr", "grep"},
+		{"gof", "gofmt"},
+		{"mak", "make"},
+		{"makef", "makefile-"},
+		{"x", ""},
+		{"", ""},
+	}
+	for _, test := range td {
+		if result := tr.LongestCommonPrefix(test.prefix); result != test.expected {
+			t.Errorf("expected '%v' for '%v', but got '%v'", test.expected, test.prefix, result)
+		}
+	}
+	if result := trie.New().LongestCommonPrefix(""); result != "" {
+		t.Errorf("expected '', but got '%v'", result)
+	}
+}
+
+func TestTrieShortestUniquePrefix(t *testing.T) {
+	tr := trie.New()
+	for _, c := range commands {
+		tr.Add(c)
+	}
+	td := []struct {
+		key      string
+		expected string
+		found    bool
+	}{
+		{"git", "gi", true},
+		{"go", "go", true},
+		{"gofmt", "gof", true},
+		{"grep", "gr", true},
+		{"make", "m", true},
+		{"g", "", false},
+		{"gitk", "", false},
+	}
+	for _, test := range td {
+		result, found := tr.ShortestUniquePrefix(test.key)
+		if result != test.expected || found != test.found {
+			t.Errorf("expected ('%v', %v) for '%v', but got ('%v', %v)", test.expected, test.found, test.key, result, found)
+		}
+	}
+}
+
+func TestTrieResolve(t *testing.T) {
+	tr := trie.New(trie.WithNormalizer(trie.FoldCase))
+	for _, c := range commands {
+		tr.Add(c)
+	}
+	tr.Add("Gunzip")
+	td := []struct {
+		abbrev   string
+		expected string
+	}{
+		{"gi", "git"},
+		{"go", "go"},
+		{"GOF", "gofmt"},
+		{"gun", "Gunzip"},
+		{"m", "make"},
+	}
+	for _, test := range td {
+		if result, err := tr.Resolve(test.abbrev); result != test.expected || err != nil {
+			t.Errorf("expected '%v' for '%v', but got '%v' and %v", test.expected, test.abbrev, result, err)
+		}
+	}
+	_, err := tr.Resolve("g")
+	var ambiguity *trie.AmbiguityError
+	if !errors.As(err, &ambiguity) || !slices.Equal(ambiguity.Candidates, []string{"git", "go", "gofmt", "grep", "Gunzip", "gzip"}) {
+		t.Errorf("expected ambiguity error, but got %v", err)
+	}
+	if _, err := tr.Resolve("x"); !errors.Is(err, trie.ErrNoMatch) {
+		t.Errorf("expected %v, but got %v", trie.ErrNoMatch, err)
+	}
+}
+
+func TestSymbolTableAbbreviations(t *testing.T) {
+	st := trie.NewSymbolTable()
+	for i, c := range commands {
+		st.Put(c, i)
+	}
+	if result := st.LongestCommonPrefix("gz"); result != "gzip" {
+		t.Errorf("expected 'gzip', but got '%v'", result)
+	}
+	if result, found := st.ShortestUniquePrefix("gofmt"); result != "gof" || !found {
+		t.Errorf("expected 'gof', but got '%v'", result)
+	}
+	if key, value, err := st.Resolve("gr"); key != "grep" || value != 3 || err != nil {
+		t.Errorf("expected ('grep', 3), but got ('%v', %v) and %v", key, value, err)
+	}
+	_, _, err := st.Resolve("go")
+	if err != nil {
+		t.Errorf("expected exact match of 'go', but got %v", err)
+	}
+	_, _, err = st.Resolve("")
+	var ambiguity *trie.AmbiguityError
+	if !errors.As(err, &ambiguity) || len(ambiguity.Candidates) != len(commands) {
+		t.Errorf("expected ambiguity error, but got %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+package trie // import "kkn.fi/trie"
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"unicode/utf8"
+)
+
+// Alphabet maps the characters of keys to dense indices. The radix of an
+// alphabet is the number of children of an R-way trie node, so an alphabet
+// that matches the data keeps the nodes small.
+type Alphabet interface {
+	// Radix returns the number of characters in the alphabet.
+	Radix() int
+	// ToIndex returns the index of c and true, or false if c is not in the
+	// alphabet. Indices are in the range [0, Radix()).
+	ToIndex(c rune) (int, bool)
+	// ToChar returns the character at index i.
+	ToChar(i int) rune
+}
+
+type (
+	extendedASCII struct{}
+	runeAlphabet  struct {
+		chars  []rune
+		index  []int        // index[c] is the index of c, or -1
+		sparse map[rune]int // indices of the characters beyond index
+	}
+	// an alphabet followed by the characters that are not in it
+	extendedAlphabet struct {
+		Alphabet
+		chars []rune
+		index map[rune]int
+	}
+	// AlphabetError is returned when a key contains a character that is not
+	// in the alphabet of a trie.
+	AlphabetError struct {
+		Key  string // the offending key
+		Char rune   // the character not in the alphabet
+		Pos  int    // byte offset of Char in Key
+	}
+)
+
+// Predefined alphabets.
+var (
+	// ExtendedASCII is the default alphabet of runes 0 to 255.
+	ExtendedASCII Alphabet = extendedASCII{}
+	// ASCII is the alphabet of runes 0 to 127.
+	ASCII = mustAlphabet(charRange(0, 127))
+	// Binary is the alphabet of the digits 0 and 1.
+	Binary = mustAlphabet("01")
+	// DNA is the alphabet of the nucleobases A, C, G and T.
+	DNA = mustAlphabet("ACGT")
+	// Decimal is the alphabet of the digits 0 to 9.
+	Decimal = mustAlphabet("0123456789")
+	// Hexadecimal is the alphabet of the digits 0 to 9 and A to F.
+	Hexadecimal = mustAlphabet("0123456789ABCDEF")
+	// LowerCase is the alphabet of the letters a to z.
+	LowerCase = mustAlphabet("abcdefghijklmnopqrstuvwxyz")
+	// UpperCase is the alphabet of the letters A to Z.
+	UpperCase = mustAlphabet("ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	// Base64 is the alphabet of the standard base64 encoding.
+	Base64 = mustAlphabet("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/")
+)
+
+// NewAlphabet returns an alphabet of the characters in chars. The index of
+// a character is its position in chars, so chars also defines the order of
+// the keys in a trie. It returns an error if chars is empty or contains
+// duplicate or invalid characters.
+func NewAlphabet(chars string) (Alphabet, error) {
+	if chars == "" {
+		return nil, errors.New("trie: empty alphabet")
+	}
+	if !utf8.ValidString(chars) {
+		return nil, errors.New("trie: alphabet is not valid UTF-8")
+	}
+	a := &runeAlphabet{
+		chars: []rune(chars),
+	}
+	// the dense index is at most a few times larger than the alphabet, and
+	// the characters beyond it are indexed by a map
+	max := rune(0)
+	for _, c := range a.chars {
+		if c > max {
+			max = c
+		}
+	}
+	n := min(int(max)+1, 256+4*len(a.chars))
+	a.index = make([]int, n)
+	for i := range a.index {
+		a.index[i] = -1
+	}
+	for i, c := range a.chars {
+		if _, ok := a.ToIndex(c); ok {
+			return nil, fmt.Errorf("trie: duplicate character %q in alphabet", c)
+		}
+		if int(c) < n {
+			a.index[c] = i
+			continue
+		}
+		if a.sparse == nil {
+			a.sparse = make(map[rune]int)
+		}
+		a.sparse[c] = i
+	}
+	return a, nil
+}
+
+func mustAlphabet(chars string) Alphabet {
+	a, err := NewAlphabet(chars)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func charRange(from, to rune) string {
+	chars := make([]rune, 0, to-from+1)
+	for c := from; c <= to; c++ {
+		chars = append(chars, c)
+	}
+	return string(chars)
+}
+
+func (extendedASCII) Radix() int {
+	return r
+}
+
+func (extendedASCII) ToIndex(c rune) (int, bool) {
+	if c < 0 || c >= r {
+		return 0, false
+	}
+	return int(c), true
+}
+
+func (extendedASCII) ToChar(i int) rune {
+	return rune(i)
+}
+
+func (a *runeAlphabet) Radix() int {
+	return len(a.chars)
+}
+
+func (a *runeAlphabet) ToIndex(c rune) (int, bool) {
+	if c >= 0 && int(c) < len(a.index) {
+		i := a.index[c]
+		return i, i != -1
+	}
+	i, ok := a.sparse[c]
+	return i, ok
+}
+
+func (a *runeAlphabet) ToChar(i int) rune {
+	return a.chars[i]
+}
+
+func (e *AlphabetError) Error() string {
+	return fmt.Sprintf("trie: character %q at offset %d of key %q is not in alphabet", e.Char, e.Pos, e.Key)
+}
+
+// extendAlphabet returns a with the characters of the normalized keys that
+// are not in a added to its end in increasing order, or a if it has them all.
+func extendAlphabet(a Alphabet, keys []string, normalize func(string) string) Alphabet {
+	var chars []rune
+	seen := make(map[rune]bool)
+	for _, key := range keys {
+		for _, c := range normalize(key) {
+			if _, ok := a.ToIndex(c); !ok && !seen[c] {
+				seen[c] = true
+				chars = append(chars, c)
+			}
+		}
+	}
+	if len(chars) == 0 {
+		return a
+	}
+	slices.Sort(chars)
+	e := &extendedAlphabet{Alphabet: a, chars: chars, index: make(map[rune]int, len(chars))}
+	for i, c := range chars {
+		e.index[c] = a.Radix() + i
+	}
+	return e
+}
+
+func (a *extendedAlphabet) Radix() int {
+	return a.Alphabet.Radix() + len(a.chars)
+}
+
+func (a *extendedAlphabet) ToIndex(c rune) (int, bool) {
+	if i, ok := a.Alphabet.ToIndex(c); ok {
+		return i, true
+	}
+	i, ok := a.index[c]
+	return i, ok
+}
+
+func (a *extendedAlphabet) ToChar(i int) rune {
+	if n := a.Alphabet.Radix(); i >= n {
+		return a.chars[i-n]
+	}
+	return a.Alphabet.ToChar(i)
+}
+
+// toIndices returns the indices of the characters of key in alphabet a.
+func toIndices(a Alphabet, key string) ([]int, error) {
+	indices := make([]int, 0, len(key))
+	for pos, c := range key {
+		i, ok := a.ToIndex(c)
+		if !ok {
+			return nil, &AlphabetError{Key: key, Char: c, Pos: pos}
+		}
+		indices = append(indices, i)
+	}
+	return indices, nil
+}
+
+// toChars returns the characters of the indices in alphabet a.
+func toChars(a Alphabet, indices []int) []rune {
+	chars := make([]rune, len(indices))
+	for i, c := range indices {
+		chars[i] = a.ToChar(c)
+	}
+	return chars
+}
+
+// bytesToIndices returns the indices of the bytes of key in alphabet a. Each
+// byte is one character.
+func bytesToIndices(a Alphabet, key []byte) ([]int, error) {
+	indices := make([]int, len(key))
+	for pos, b := range key {
+		i, ok := a.ToIndex(rune(b))
+		if !ok {
+			return nil, &AlphabetError{Key: string(key), Char: rune(b), Pos: pos}
+		}
+		indices[pos] = i
+	}
+	return indices, nil
+}
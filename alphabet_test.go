@@ -0,0 +1,70 @@
+package trie_test
+
+import (
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+func TestNewAlphabet(t *testing.T) {
+	a, err := trie.NewAlphabet("TGCA")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if a.Radix() != 4 {
+		t.Errorf("expected radix 4, but got %d", a.Radix())
+	}
+	if i, ok := a.ToIndex('C'); !ok || i != 2 {
+		t.Errorf("expected index 2, but got %d", i)
+	}
+	if _, ok := a.ToIndex('X'); ok {
+		t.Error("expected 'X' not to be in alphabet")
+	}
+	if a.ToChar(0) != 'T' {
+		t.Errorf("expected 'T', but got %q", a.ToChar(0))
+	}
+
+	for _, chars := range []string{"", "abca", "\xff", "a\U0010FFFFb\U0010FFFF"} {
+		if _, err := trie.NewAlphabet(chars); err == nil {
+			t.Errorf("expected error for alphabet %q", chars)
+		}
+	}
+}
+
+func TestNewAlphabetSparse(t *testing.T) {
+	a, err := trie.NewAlphabet("a\U0010FFFF😀")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	for i, c := range []rune("a\U0010FFFF😀") {
+		if j, ok := a.ToIndex(c); !ok || j != i || a.ToChar(i) != c {
+			t.Errorf("expected index %d of %q, but got %d", i, c, j)
+		}
+	}
+	if _, ok := a.ToIndex('b'); ok {
+		t.Error("expected 'b' not to be in alphabet")
+	}
+	if _, ok := a.ToIndex('\U0010FFFE'); ok {
+		t.Error("expected U+10FFFE not to be in alphabet")
+	}
+	st := trie.NewSymbolTable(trie.WithAlphabet(a))
+	st.Put("a😀\U0010FFFF", 1)
+	if st.Get("a😀\U0010FFFF") != 1 {
+		t.Errorf("expected 1, but got %v", st.Get("a😀\U0010FFFF"))
+	}
+}
+
+func TestAlphabetError(t *testing.T) {
+	st := trie.NewSymbolTable(trie.WithAlphabet(trie.DNA))
+	err := st.Put("GATXACA", 1)
+	e, ok := err.(*trie.AlphabetError)
+	if !ok {
+		t.Fatalf("expected *trie.AlphabetError, but got %v", err)
+	}
+	if e.Char != 'X' || e.Pos != 3 || e.Key != "GATXACA" {
+		t.Errorf("unexpected error %+v", e)
+	}
+	if !st.IsEmpty() {
+		t.Error("expected symbol table to be empty")
+	}
+}
@@ -0,0 +1,209 @@
+package trie // import "kkn.fi/trie"
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+)
+
+var (
+	// ErrNotSorted is returned when the input of a build function is not
+	// in sorted order.
+	ErrNotSorted = errors.New("trie: keys are not sorted")
+	// ErrDuplicateKey is returned when the input of a build function
+	// contains a key more than once.
+	ErrDuplicateKey = errors.New("trie: duplicate key")
+)
+
+type tsEntry struct {
+	key      []rune
+	value    interface{}
+	spelling string
+}
+
+// BuildFromSorted returns a trie of keys. The keys must be in the order in
+// which Keys returns them and must not contain duplicates. Empty keys are
+// ignored. Instead of walking from the root for each key, the path of the
+// previous key is reused, so the time is proportional to the number of nodes
+// of the trie.
+func BuildFromSorted(keys iter.Seq[string], opts ...Option) (*Trie, error) {
+	t := New(opts...)
+	a := t.Alphabet()
+	t.root = t.newNode()
+	path := []*node{t.root}
+	var prev []int
+	var prevKey string
+	for key := range keys {
+		norm := t.normalized(key)
+		if norm == "" {
+			continue
+		}
+		indices, err := toIndices(a, norm)
+		if err != nil {
+			return nil, err
+		}
+		d, err := checkSorted(prev, indices, prevKey, key)
+		if err != nil {
+			return nil, err
+		}
+		path = finishPath(path, d+1)
+		for ; d < len(indices); d++ {
+			x := t.newNode()
+			path[d].next[indices[d]] = x
+			path = append(path, x)
+		}
+		x := path[len(path)-1]
+		x.isString = true
+		x.size = 1
+		if t.normalize != nil {
+			x.key = key
+		}
+		t.length++
+		prev, prevKey = indices, key
+	}
+	finishPath(path, 1)
+	if t.length == 0 {
+		t.root = nil
+	}
+	return t, nil
+}
+
+// finishPath truncates path to its first n nodes, adding the sizes of the
+// removed nodes, which have no more keys to come, to their parents.
+func finishPath(path []*node, n int) []*node {
+	for i := len(path) - 1; i >= n; i-- {
+		path[i-1].size += path[i].size
+	}
+	return path[:n]
+}
+
+// BuildSymbolTableFromSorted returns a symbol table of the key-value pairs
+// of pairs. The keys must be in the order in which Keys returns them and
+// must not contain duplicates. Empty keys and nil values are ignored.
+// Instead of walking from the root for each key, the path of the previous
+// key is reused, so the time is proportional to the number of nodes of the
+// trie.
+func BuildSymbolTableFromSorted(pairs iter.Seq2[string, interface{}], opts ...Option) (*SymbolTable, error) {
+	t := NewSymbolTable(opts...)
+	a := t.Alphabet()
+	t.root = t.newNode()
+	path := []*sTNode{t.root}
+	var prev []int
+	var prevKey string
+	for key, value := range pairs {
+		norm := t.normalized(key)
+		if norm == "" || value == nil {
+			continue
+		}
+		indices, err := toIndices(a, norm)
+		if err != nil {
+			return nil, err
+		}
+		d, err := checkSorted(prev, indices, prevKey, key)
+		if err != nil {
+			return nil, err
+		}
+		path = path[:d+1]
+		for ; d < len(indices); d++ {
+			x := t.newNode()
+			path[d].next[indices[d]] = x
+			path = append(path, x)
+		}
+		x := path[len(path)-1]
+		x.value = value
+		if t.normalize != nil {
+			x.key = key
+		}
+		t.length++
+		prev, prevKey = indices, key
+	}
+	if t.length == 0 {
+		t.root = nil
+	}
+	return t, nil
+}
+
+// BuildTernarySearchFromSorted returns a ternary search trie of the
+// key-value pairs of pairs. The keys must be in the order in which Keys
+// returns them and must not contain duplicates. Empty keys and nil values
+// are ignored. The trie is balanced by using the median character of each
+// level as the root of the level.
+func BuildTernarySearchFromSorted(pairs iter.Seq2[string, interface{}], opts ...Option) (*TernarySearch, error) {
+	t := NewTernarySearch(opts...)
+	var entries []tsEntry
+	var prev []int
+	var prevKey string
+	for key, value := range pairs {
+		norm := []rune(t.normalized(key))
+		if len(norm) == 0 || value == nil {
+			continue
+		}
+		indices := make([]int, len(norm))
+		for i, c := range norm {
+			indices[i] = int(c)
+		}
+		if _, err := checkSorted(prev, indices, prevKey, key); err != nil {
+			return nil, err
+		}
+		entries = append(entries, tsEntry{key: norm, value: value, spelling: key})
+		prev, prevKey = indices, key
+	}
+	t.root = t.balanced(groupEntries(entries, 0), 0)
+	t.length = len(entries)
+	return t, nil
+}
+
+// returns a balanced subtrie of the groups of entries that have the same
+// character at d
+func (t *TernarySearch) balanced(groups [][]tsEntry, d int) *tSNode {
+	if len(groups) == 0 {
+		return nil
+	}
+	m := len(groups) / 2
+	g := groups[m]
+	x := &tSNode{
+		c:   g[0].key[d],
+		gen: t.gen,
+	}
+	if len(g[0].key) == d+1 {
+		x.value = g[0].value
+		if t.normalize != nil {
+			x.key = g[0].spelling
+		}
+		g = g[1:]
+	}
+	x.left = t.balanced(groups[:m], d)
+	x.mid = t.balanced(groupEntries(g, d+1), d+1)
+	x.right = t.balanced(groups[m+1:], d)
+	return x
+}
+
+// returns sorted entries grouped by their character at d
+func groupEntries(entries []tsEntry, d int) [][]tsEntry {
+	var groups [][]tsEntry
+	for i, j := 0, 0; i < len(entries); i = j {
+		for j = i + 1; j < len(entries) && entries[j].key[d] == entries[i].key[d]; j++ {
+		}
+		groups = append(groups, entries[i:j])
+	}
+	return groups
+}
+
+// checkSorted returns the length of the common prefix of the character
+// indices of the previous and the next key, or an error if next is not after
+// prev.
+func checkSorted(prev, next []int, prevKey, nextKey string) (int, error) {
+	d := 0
+	for d < len(prev) && d < len(next) && prev[d] == next[d] {
+		d++
+	}
+	switch {
+	case prev == nil:
+		return 0, nil
+	case d == len(prev) && d == len(next):
+		return 0, fmt.Errorf("%w: %q", ErrDuplicateKey, nextKey)
+	case d == len(next) || d < len(prev) && next[d] < prev[d]:
+		return 0, fmt.Errorf("%w: %q is before %q", ErrNotSorted, nextKey, prevKey)
+	}
+	return d, nil
+}
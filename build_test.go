@@ -0,0 +1,117 @@
+package trie_test
+
+import (
+	"errors"
+	"iter"
+	"maps"
+	"slices"
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+var sortedData = []string{"by", "sea", "sells", "she", "shells", "shore", "the"}
+
+func sortedPairs(keys []string) iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		for i, key := range keys {
+			if !yield(key, i) {
+				return
+			}
+		}
+	}
+}
+
+func TestBuildFromSorted(t *testing.T) {
+	st, err := trie.BuildFromSorted(slices.Values(sortedData))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if st.Len() != len(sortedData) {
+		t.Errorf("expected len %d, but got %d", len(sortedData), st.Len())
+	}
+	if !slices.Equal(st.Keys(), sortedData) {
+		t.Errorf("expected %v, but got %v", sortedData, st.Keys())
+	}
+	st.Delete("sea")
+	st.Add("seashore")
+	if !st.Contains("seashore") || st.Contains("sea") {
+		t.Error("expected built trie to be modifiable")
+	}
+}
+
+func TestBuildFromSortedErrors(t *testing.T) {
+	td := []struct {
+		keys []string
+		err  error
+	}{
+		{[]string{"by", "sea", "sea"}, trie.ErrDuplicateKey},
+		{[]string{"sea", "by"}, trie.ErrNotSorted},
+		{[]string{"shells", "she"}, trie.ErrNotSorted},
+	}
+	for _, test := range td {
+		if _, err := trie.BuildFromSorted(slices.Values(test.keys)); !errors.Is(err, test.err) {
+			t.Errorf("expected %v for %v, but got %v", test.err, test.keys, err)
+		}
+		if _, err := trie.BuildSymbolTableFromSorted(sortedPairs(test.keys)); !errors.Is(err, test.err) {
+			t.Errorf("expected %v for %v, but got %v", test.err, test.keys, err)
+		}
+		if _, err := trie.BuildTernarySearchFromSorted(sortedPairs(test.keys)); !errors.Is(err, test.err) {
+			t.Errorf("expected %v for %v, but got %v", test.err, test.keys, err)
+		}
+	}
+	_, err := trie.BuildFromSorted(slices.Values([]string{"ab", "AB"}), trie.WithNormalizer(trie.FoldCase))
+	if !errors.Is(err, trie.ErrDuplicateKey) {
+		t.Errorf("expected duplicate key error, but got %v", err)
+	}
+}
+
+func TestBuildSymbolTableFromSorted(t *testing.T) {
+	st, err := trie.BuildSymbolTableFromSorted(sortedPairs(sortedData))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if st.Len() != len(sortedData) {
+		t.Errorf("expected len %d, but got %d", len(sortedData), st.Len())
+	}
+	for i, key := range sortedData {
+		if st.Get(key) != i {
+			t.Errorf("expected key '%v' to return %d, but got %v", key, i, st.Get(key))
+		}
+	}
+	if prefix := st.LongestPrefixOf("shellsort"); prefix != "shells" {
+		t.Errorf("expected 'shells', but got '%v'", prefix)
+	}
+
+	empty, err := trie.BuildSymbolTableFromSorted(maps.All(map[string]interface{}{}))
+	if err != nil || !empty.IsEmpty() {
+		t.Errorf("expected empty symbol table, but got %v", err)
+	}
+}
+
+func TestBuildTernarySearchFromSorted(t *testing.T) {
+	ts, err := trie.BuildTernarySearchFromSorted(sortedPairs(sortedData))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if ts.Len() != len(sortedData) {
+		t.Errorf("expected len %d, but got %d", len(sortedData), ts.Len())
+	}
+	for i, key := range sortedData {
+		if ts.Get(key) != i {
+			t.Errorf("expected key '%v' to return %d, but got %v", key, i, ts.Get(key))
+		}
+	}
+	if !slices.Equal(ts.Keys(), sortedData) {
+		t.Errorf("expected %v, but got %v", sortedData, ts.Keys())
+	}
+	if keys := ts.KeysWithPrefix("sh"); !slices.Equal(keys, []string{"she", "shells", "shore"}) {
+		t.Errorf("expected [she shells shore], but got %v", keys)
+	}
+}
+
+func BenchmarkBuildSymbolTableFromSorted(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		trie.BuildSymbolTableFromSorted(sortedPairs(sortedData))
+	}
+}
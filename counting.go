@@ -0,0 +1,293 @@
+package trie // import "kkn.fi/trie"
+
+import "container/heap"
+
+type (
+	cntNode struct {
+		c     rune
+		left  *cntNode
+		mid   *cntNode
+		right *cntNode
+		count int    // count of the key that ends at node
+		sum   int    // sum of the counts in the subtree rooted at node
+		max   int    // greatest count in the subtree rooted at node
+		key   string // original spelling of a normalized key
+	}
+	// KeyCount is a key and its count in a CountingTrie.
+	KeyCount struct {
+		Key   string
+		Count int
+	}
+	// CountingTrie counts the occurrences of string keys, for example of the
+	// tokens or the n-grams of a corpus. It implements ternary search trie,
+	// where each node knows the sum and the greatest of the counts below it,
+	// so the total count of the keys that start with a prefix and the most
+	// frequent of them are found without visiting all of the keys.
+	//
+	// The keys can be normalized with WithNormalizer, for example to count
+	// the words case-insensitively. The functions that list keys return the
+	// original spelling of each key as it was last incremented.
+	CountingTrie struct {
+		length    int
+		root      *cntNode
+		normalize Normalizer
+	}
+)
+
+// NewCountingTrie returns an empty counting trie.
+func NewCountingTrie(opts ...Option) *CountingTrie {
+	c := newConfig(opts)
+	return &CountingTrie{
+		normalize: c.normalize,
+	}
+}
+
+// returns key in the normalized form used for storage and lookup
+func (t *CountingTrie) normalized(key string) string {
+	if t.normalize == nil {
+		return key
+	}
+	return t.normalize(key)
+}
+
+// Increment adds delta to the count of key and returns the new count. A
+// negative delta decrements the count, which never goes below zero, and a key
+// whose count is zero is removed. If key is empty this function will
+// silently return zero.
+func (t *CountingTrie) Increment(key string, delta int) int {
+	norm := t.normalized(key)
+	if norm == "" {
+		return 0
+	}
+	if delta == 0 {
+		return t.Count(key)
+	}
+	count := 0
+	t.root = t.increment(t.root, []rune(norm), delta, 0, key, &count)
+	return count
+}
+
+// adds delta to the count of key in the subtrie rooted at x, storing the new
+// count to count
+func (t *CountingTrie) increment(x *cntNode, key []rune, delta, d int, spelling string, count *int) *cntNode {
+	c := key[d]
+	if x == nil {
+		if delta < 0 {
+			return nil
+		}
+		x = &cntNode{c: c}
+	}
+	if c < x.c {
+		x.left = t.increment(x.left, key, delta, d, spelling, count)
+	} else if c > x.c {
+		x.right = t.increment(x.right, key, delta, d, spelling, count)
+	} else if d < len(key)-1 {
+		x.mid = t.increment(x.mid, key, delta, d+1, spelling, count)
+	} else {
+		old := x.count
+		x.count = max(old+delta, 0)
+		if old == 0 && x.count > 0 {
+			t.length++
+		} else if old > 0 && x.count == 0 {
+			t.length--
+		}
+		if x.count == 0 {
+			x.key = ""
+		} else if t.normalize != nil && delta > 0 {
+			x.key = spelling
+		}
+		*count = x.count
+	}
+	if x.count == 0 && x.mid == nil {
+		return unlinkCount(x)
+	}
+	x.update()
+	return x
+}
+
+// unlinkCount returns the binary search tree of the siblings of x without x.
+func unlinkCount(x *cntNode) *cntNode {
+	if x.left == nil {
+		return x.right
+	}
+	if x.right == nil {
+		return x.left
+	}
+	m, right := removeMinCount(x.right)
+	m.left, m.right = x.left, right
+	m.update()
+	return m
+}
+
+// removeMinCount returns the least node of the binary search tree rooted at x
+// and the tree without it.
+func removeMinCount(x *cntNode) (min, rest *cntNode) {
+	if x.left == nil {
+		return x, x.right
+	}
+	min, x.left = removeMinCount(x.left)
+	x.update()
+	return min, x
+}
+
+// update recomputes the sum and the greatest count of the subtree rooted at
+// x from its children.
+func (x *cntNode) update() {
+	x.sum, x.max = x.count, x.count
+	for _, y := range []*cntNode{x.left, x.mid, x.right} {
+		if y != nil {
+			x.sum += y.sum
+			x.max = max(x.max, y.max)
+		}
+	}
+}
+
+// Count returns the count of key, or zero if key is not found.
+func (t *CountingTrie) Count(key string) int {
+	key = t.normalized(key)
+	if key == "" {
+		return 0
+	}
+	x := t.get(t.root, []rune(key))
+	if x == nil {
+		return 0
+	}
+	return x.count
+}
+
+// return the node of the string s
+func (t *CountingTrie) get(x *cntNode, s []rune) *cntNode {
+	for d := 0; x != nil; {
+		if c := s[d]; c < x.c {
+			x = x.left
+		} else if c > x.c {
+			x = x.right
+		} else if d < len(s)-1 {
+			x, d = x.mid, d+1
+		} else {
+			return x
+		}
+	}
+	return nil
+}
+
+// TotalWithPrefix returns the sum of the counts of the keys that start with
+// prefix. It takes time proportional to the length of prefix.
+func (t *CountingTrie) TotalWithPrefix(prefix string) int {
+	p := []rune(t.normalized(prefix))
+	if len(p) == 0 {
+		return t.root.total()
+	}
+	x := t.get(t.root, p)
+	if x == nil {
+		return 0
+	}
+	return x.count + x.mid.total()
+}
+
+// returns the sum of the counts in the subtree rooted at x
+func (x *cntNode) total() int {
+	if x == nil {
+		return 0
+	}
+	return x.sum
+}
+
+// MostFrequentWithPrefix returns at most k keys that start with prefix and
+// their counts, from the most frequent to the least frequent and
+// alphabetically among equal counts. A limit of zero or less means no limit.
+// The subtries are searched best first by their greatest count, so only the
+// subtries that may contain one of the k keys are visited.
+func (t *CountingTrie) MostFrequentWithPrefix(prefix string, k int) []KeyCount {
+	var results []KeyCount
+	p := []rune(t.normalized(prefix))
+	h := new(countHeap)
+	if len(p) == 0 {
+		h.pushNode(t.root, nil)
+	} else if x := t.get(t.root, p); x != nil {
+		if x.count > 0 {
+			heap.Push(h, countItem{chars: p, node: x, count: x.count, isKey: true})
+		}
+		h.pushNode(x.mid, p)
+	}
+	for h.Len() > 0 && (k <= 0 || len(results) < k) {
+		item := heap.Pop(h).(countItem)
+		x := item.node
+		if item.isKey {
+			results = append(results, KeyCount{Key: x.spelling(item.chars), Count: x.count})
+			continue
+		}
+		chars := append(item.chars[:len(item.chars):len(item.chars)], x.c)
+		if x.count > 0 {
+			heap.Push(h, countItem{chars: chars, node: x, count: x.count, isKey: true})
+		}
+		h.pushNode(x.left, item.chars)
+		h.pushNode(x.mid, chars)
+		h.pushNode(x.right, item.chars)
+	}
+	return results
+}
+
+// Len returns the number of keys whose count is greater than zero.
+func (t *CountingTrie) Len() int {
+	return t.length
+}
+
+// IsEmpty returns true if trie is empty.
+func (t *CountingTrie) IsEmpty() bool {
+	return t.length == 0
+}
+
+// returns the original spelling of the key with characters chars
+func (x *cntNode) spelling(chars []rune) string {
+	if x.key != "" {
+		return x.key
+	}
+	return string(chars)
+}
+
+type (
+	// countItem is either a key, or a subtree whose keys start with chars
+	// and whose greatest count is count.
+	countItem struct {
+		chars []rune
+		node  *cntNode
+		count int
+		isKey bool
+	}
+	// countHeap is a max-heap of countItems. Among equal counts, subtrees are
+	// before keys so that all of the keys with a count are found before the
+	// first one of them is popped, and keys are in alphabetical order.
+	countHeap []countItem
+)
+
+func (h countHeap) Len() int { return len(h) }
+
+func (h countHeap) Less(i, j int) bool {
+	a, b := h[i], h[j]
+	if a.count != b.count {
+		return a.count > b.count
+	}
+	if a.isKey != b.isKey {
+		return !a.isKey
+	}
+	return string(a.chars) < string(b.chars)
+}
+
+func (h countHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *countHeap) Push(x interface{}) { *h = append(*h, x.(countItem)) }
+
+func (h *countHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// pushes the subtree rooted at x, whose keys start with chars
+func (h *countHeap) pushNode(x *cntNode, chars []rune) {
+	if x != nil && x.max > 0 {
+		heap.Push(h, countItem{chars: chars, node: x, count: x.max})
+	}
+}
@@ -0,0 +1,114 @@
+package trie_test
+
+import (
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+func TestCountingTrieIncrement(t *testing.T) {
+	ct := trie.NewCountingTrie()
+	for _, w := range data {
+		ct.Increment(w, 1)
+	}
+	if ct.Count("sea") != 2 || ct.Count("she") != 1 || ct.Count("s") != 0 {
+		t.Errorf("expected counts 2, 1 and 0, but got %d, %d and %d", ct.Count("sea"), ct.Count("she"), ct.Count("s"))
+	}
+	if ct.Len() != 7 {
+		t.Errorf("expected length 7, but got %d", ct.Len())
+	}
+	if c := ct.Increment("sea", -5); c != 0 || ct.Len() != 6 {
+		t.Errorf("expected count 0 and length 6, but got %d and %d", c, ct.Len())
+	}
+	if c := ct.Increment("seashell", -1); c != 0 || ct.Len() != 6 {
+		t.Errorf("expected count 0 and length 6, but got %d and %d", c, ct.Len())
+	}
+	if ct.TotalWithPrefix("se") != 1 {
+		t.Errorf("expected total 1, but got %d", ct.TotalWithPrefix("se"))
+	}
+}
+
+func TestCountingTrieTotalWithPrefix(t *testing.T) {
+	ct := trie.NewCountingTrie()
+	for _, w := range data {
+		ct.Increment(w, 2)
+	}
+	td := []struct {
+		prefix   string
+		expected int
+	}{
+		{"", 16},
+		{"s", 12},
+		{"sh", 6},
+		{"she", 4},
+		{"sea", 4},
+		{"x", 0},
+	}
+	for _, test := range td {
+		if total := ct.TotalWithPrefix(test.prefix); total != test.expected {
+			t.Errorf("expected total %d for '%v', but got %d", test.expected, test.prefix, total)
+		}
+	}
+}
+
+func TestCountingTrieMostFrequentWithPrefix(t *testing.T) {
+	ct := trie.NewCountingTrie()
+	ct.Increment("the", 10)
+	ct.Increment("then", 3)
+	ct.Increment("they", 7)
+	ct.Increment("there", 7)
+	ct.Increment("this", 5)
+	ct.Increment("a", 8)
+	td := []struct {
+		prefix   string
+		k        int
+		expected []trie.KeyCount
+	}{
+		{"th", 3, []trie.KeyCount{{"the", 10}, {"there", 7}, {"they", 7}}},
+		{"the", 0, []trie.KeyCount{{"the", 10}, {"there", 7}, {"they", 7}, {"then", 3}}},
+		{"", 2, []trie.KeyCount{{"the", 10}, {"a", 8}}},
+		{"x", 2, nil},
+	}
+	for _, test := range td {
+		if result := ct.MostFrequentWithPrefix(test.prefix, test.k); !slices.Equal(result, test.expected) {
+			t.Errorf("expected %v for '%v', but got %v", test.expected, test.prefix, result)
+		}
+	}
+}
+
+func TestCountingTrieRandom(t *testing.T) {
+	ct := trie.NewCountingTrie()
+	counts := make(map[string]int)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		key := string([]byte{'a' + byte(r.Intn(3)), 'a' + byte(r.Intn(3)), 'a' + byte(r.Intn(3))})[:1+r.Intn(3)]
+		delta := r.Intn(7) - 3
+		counts[key] = max(counts[key]+delta, 0)
+		if c := ct.Increment(key, delta); c != counts[key] {
+			t.Fatalf("expected count %d for '%v', but got %d", counts[key], key, c)
+		}
+	}
+	var expected []trie.KeyCount
+	total := 0
+	for key, count := range counts {
+		if count > 0 {
+			expected = append(expected, trie.KeyCount{Key: key, Count: count})
+			total += count
+		}
+	}
+	sort.Slice(expected, func(i, j int) bool {
+		if expected[i].Count != expected[j].Count {
+			return expected[i].Count > expected[j].Count
+		}
+		return expected[i].Key < expected[j].Key
+	})
+	if ct.Len() != len(expected) || ct.TotalWithPrefix("") != total {
+		t.Errorf("expected length %d and total %d, but got %d and %d", len(expected), total, ct.Len(), ct.TotalWithPrefix(""))
+	}
+	if result := ct.MostFrequentWithPrefix("", 0); !slices.Equal(result, expected) {
+		t.Errorf("expected %v, but got %v", expected, result)
+	}
+}
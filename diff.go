@@ -0,0 +1,154 @@
+package trie // import "kkn.fi/trie"
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"reflect"
+)
+
+// ErrPatchConflict is returned when a change of a patch does not apply to
+// the old value of its key.
+var ErrPatchConflict = errors.New("trie: patch does not apply")
+
+// ChangeType tells how a key differs between two symbol tables.
+type ChangeType int
+
+const (
+	// ChangeAdded means that the key is only in the second symbol table.
+	ChangeAdded ChangeType = iota
+	// ChangeRemoved means that the key is only in the first symbol table.
+	ChangeRemoved
+	// ChangeModified means that the key has different values.
+	ChangeModified
+)
+
+func (c ChangeType) String() string {
+	switch c {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// Change is a difference of a key between two symbol tables. Old is nil if
+// the key was added and New is nil if it was removed.
+type Change struct {
+	Type ChangeType
+	Key  string
+	Old  interface{}
+	New  interface{}
+}
+
+// Diff returns an iterator over the changes that turn a into b, in the
+// order of the keys. The values are compared with reflect.DeepEqual.
+//
+// The tries are walked in lockstep, and the subtries that are the same
+// nodes, like the unchanged parts of a snapshot, are skipped, so diffing a
+// symbol table with its snapshot takes time proportional to the nodes written
+// to since the snapshot. If the symbol tables have different alphabets, all
+// of the keys are compared.
+func Diff(a, b *SymbolTable) iter.Seq[Change] {
+	return func(yield func(Change) bool) {
+		if sameAlphabet(a.Alphabet(), b.Alphabet()) {
+			diff(a.Alphabet(), a.root, b.root, nil, yield)
+			return
+		}
+		for key, old := range a.All() {
+			value := b.Get(key)
+			switch {
+			case value == nil:
+				if !yield(Change{Type: ChangeRemoved, Key: key, Old: old}) {
+					return
+				}
+			case !reflect.DeepEqual(old, value):
+				if !yield(Change{Type: ChangeModified, Key: key, Old: old, New: value}) {
+					return
+				}
+			}
+		}
+		for key, value := range b.All() {
+			if !a.Contains(key) && !yield(Change{Type: ChangeAdded, Key: key, New: value}) {
+				return
+			}
+		}
+	}
+}
+
+// yields the changes between the subtries rooted at x and y, whose keys
+// start with chars, returning false if the iteration was stopped
+func diff(a Alphabet, x, y *sTNode, chars []rune, yield func(Change) bool) bool {
+	if x == y {
+		return true
+	}
+	var old, value interface{}
+	var xNext, yNext []*sTNode
+	if x != nil {
+		old, xNext = x.value, x.next
+	}
+	if y != nil {
+		value, yNext = y.value, y.next
+	}
+	var c Change
+	switch {
+	case old == nil && value == nil:
+	case old == nil:
+		c = Change{Type: ChangeAdded, Key: y.spelling(chars), New: value}
+	case value == nil:
+		c = Change{Type: ChangeRemoved, Key: x.spelling(chars), Old: old}
+	case !reflect.DeepEqual(old, value):
+		c = Change{Type: ChangeModified, Key: y.spelling(chars), Old: old, New: value}
+	}
+	if c.Key != "" && !yield(c) {
+		return false
+	}
+	for i := 0; i < a.Radix(); i++ {
+		var xc, yc *sTNode
+		if xNext != nil {
+			xc = xNext[i]
+		}
+		if yNext != nil {
+			yc = yNext[i]
+		}
+		if (xc != nil || yc != nil) && !diff(a, xc, yc, append(chars, a.ToChar(i)), yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// returns true if a and b have the same characters
+func sameAlphabet(a, b Alphabet) bool {
+	if a.Radix() != b.Radix() {
+		return false
+	}
+	for i := 0; i < a.Radix(); i++ {
+		if a.ToChar(i) != b.ToChar(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Patch applies the changes, for example a Diff, to the symbol table. The
+// changes are applied in a transaction, and if the value of the key of a
+// change is not its Old value, none of the changes are applied and the
+// returned error wraps ErrPatchConflict.
+func (t *SymbolTable) Patch(changes iter.Seq[Change]) error {
+	tx := t.Begin()
+	for c := range changes {
+		if old := tx.Get(c.Key); !reflect.DeepEqual(old, c.Old) {
+			tx.Rollback()
+			return fmt.Errorf("%w: %s of %q", ErrPatchConflict, c.Type, c.Key)
+		}
+		if err := tx.Put(c.Key, c.New); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
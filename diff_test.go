@@ -0,0 +1,87 @@
+package trie_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+func TestDiff(t *testing.T) {
+	a := trie.NewSymbolTable()
+	for i, w := range data {
+		a.Put(w, i)
+	}
+	b := a.Snapshot()
+	b.Put("sea", 10)
+	b.Delete("she")
+	b.Put("seashell", 11)
+	b.Put("by", []int{4})
+	expected := []trie.Change{
+		{Type: trie.ChangeModified, Key: "by", Old: 4, New: []int{4}},
+		{Type: trie.ChangeModified, Key: "sea", Old: 6, New: 10},
+		{Type: trie.ChangeAdded, Key: "seashell", New: 11},
+		{Type: trie.ChangeRemoved, Key: "she", Old: 0},
+	}
+	changes := slices.Collect(trie.Diff(a, b))
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %v, but got %v", expected, changes)
+	}
+	for i, c := range changes {
+		e := expected[i]
+		if c.Type != e.Type || c.Key != e.Key || c.Old != e.Old || e.Key != "by" && c.New != e.New {
+			t.Errorf("expected %+v, but got %+v", e, c)
+		}
+	}
+	if err := a.Patch(trie.Diff(a, b)); err != nil {
+		t.Fatal(err)
+	}
+	if changes := slices.Collect(trie.Diff(a, b)); len(changes) != 0 {
+		t.Errorf("expected no changes after patch, but got %v", changes)
+	}
+}
+
+// label is a value whose String does not tell its values apart.
+type label struct{ n int }
+
+func (label) String() string { return "label" }
+
+func TestDiffValuesWithSameString(t *testing.T) {
+	a := trie.NewSymbolTable()
+	b := trie.NewSymbolTable()
+	for i, w := range data {
+		a.Put(w, label{i})
+		b.Put(w, label{i})
+	}
+	b.Put("sea", label{10})
+	a.RootHash()
+	b.RootHash()
+	changes := slices.Collect(trie.Diff(a, b))
+	if len(changes) != 1 || changes[0].Key != "sea" || changes[0].New != (label{10}) {
+		t.Errorf("expected change of 'sea', but got %v", changes)
+	}
+}
+
+func TestPatchConflict(t *testing.T) {
+	a := trie.NewSymbolTable()
+	b := trie.NewSymbolTable(trie.WithAlphabet(trie.LowerCase))
+	for i, w := range data {
+		a.Put(w, i)
+		b.Put(w, i)
+	}
+	b.Put("sea", 10)
+	b.Delete("the")
+	changes := slices.Collect(trie.Diff(a, b))
+	if len(changes) != 2 {
+		t.Errorf("expected 2 changes between alphabets, but got %v", changes)
+	}
+	a.Put("the", 11)
+	err := a.Patch(slices.Values(changes))
+	if !errors.Is(err, trie.ErrPatchConflict) {
+		t.Errorf("expected %v, but got %v", trie.ErrPatchConflict, err)
+	}
+	if a.Get("sea") != 6 {
+		t.Errorf("expected no changes to be applied, but got %v", a.Get("sea"))
+	}
+}
@@ -0,0 +1,423 @@
+package trie // import "kkn.fi/trie"
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	walFile      = "wal"
+	snapshotFile = "snapshot"
+
+	opPut    byte = 1
+	opDelete byte = 2
+
+	recordHeader = 12      // length and checksum of the payload, and checksum of both
+	maxRecord    = 1 << 30 // greatest length of a payload
+)
+
+// ErrCorrupt is returned when the snapshot file or the log of a
+// DurableSymbolTable is corrupt.
+var ErrCorrupt = errors.New("trie: corrupt durable symbol table")
+
+type (
+	// Codec encodes the values of a DurableSymbolTable to bytes and back.
+	Codec interface {
+		Marshal(value interface{}) ([]byte, error)
+		Unmarshal(data []byte) (interface{}, error)
+	}
+	// GobCodec encodes values with encoding/gob. The concrete types of the
+	// values other than the basic types must be registered with
+	// gob.Register.
+	GobCodec struct{}
+	// DurableSymbolTable is a SymbolTable whose writes survive a crash. Each
+	// write is appended to a write-ahead log file and synced to the disk
+	// before it is applied. Open replays the log, and a torn record at the
+	// end of the log, left by a crash in the middle of a write, is
+	// truncated. Any other bad record, like a complete record whose
+	// checksum does not match, is not truncated, and Open returns an error
+	// wrapping ErrCorrupt.
+	//
+	// The log is compacted into a snapshot file of the key-value pairs after
+	// CompactAfter writes, or when Compact is called. The snapshot is
+	// written to a temporary file that is renamed over the previous
+	// snapshot, so a crash during compaction leaves either the old or the
+	// new snapshot.
+	//
+	// The keys are logged as they were given, so the options of the symbol
+	// table, like the normalizers, must be the same each time the directory
+	// is opened.
+	DurableSymbolTable struct {
+		// CompactAfter is the number of writes after which the log is
+		// compacted. Zero or less means that the log is compacted only by
+		// Compact. It is 10000 by default.
+		CompactAfter int
+		// OnCompactError is called with the error of a compaction started by
+		// a write, if it is not nil. The write itself has succeeded, and the
+		// compaction is retried after the next write.
+		OnCompactError func(err error)
+
+		table   *SymbolTable
+		codec   Codec
+		dir     string
+		wal     *os.File
+		records int   // number of records in the log
+		failed  error // error that left the log in an unknown state
+	}
+)
+
+// Marshal returns the gob encoding of value.
+func (GobCodec) Marshal(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal returns the value of the gob encoding data.
+func (GobCodec) Unmarshal(data []byte) (interface{}, error) {
+	var value interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// OpenDurableSymbolTable opens the durable symbol table stored in the
+// directory dir, creating the directory if it does not exist. The values are
+// encoded with the codec set with WithCodec, by default GobCodec.
+func OpenDurableSymbolTable(dir string, opts ...Option) (*DurableSymbolTable, error) {
+	c := newConfig(opts)
+	t := &DurableSymbolTable{
+		CompactAfter: 10000,
+		table:        NewSymbolTable(opts...),
+		codec:        c.codec,
+		dir:          dir,
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := t.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	wal, err := os.OpenFile(filepath.Join(dir, walFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	t.wal = wal
+	if err := t.replay(); err != nil {
+		wal.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// loads the key-value pairs of the snapshot file, if there is one
+func (t *DurableSymbolTable) loadSnapshot() error {
+	f, err := os.Open(filepath.Join(t.dir, snapshotFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		op, key, value, _, err := readRecord(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		if err := t.apply(op, key, value); err != nil {
+			return err
+		}
+	}
+}
+
+// replays the records of the log and truncates a torn record at its end
+func (t *DurableSymbolTable) replay() error {
+	r := bufio.NewReader(t.wal)
+	var offset int64
+	for {
+		op, key, value, n, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// a crash can only leave an incomplete record at the end of
+			// the log, any other bad record is corruption
+			if err != io.ErrUnexpectedEOF {
+				return fmt.Errorf("%w: log record at offset %d: %v", ErrCorrupt, offset, err)
+			}
+			if err := t.wal.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+		if err := t.apply(op, key, value); err != nil {
+			return err
+		}
+		offset += int64(n)
+		t.records++
+	}
+	_, err := t.wal.Seek(offset, io.SeekStart)
+	return err
+}
+
+// applies a record to the symbol table
+func (t *DurableSymbolTable) apply(op byte, key string, data []byte) error {
+	switch op {
+	case opPut:
+		value, err := t.codec.Unmarshal(data)
+		if err != nil {
+			return err
+		}
+		return t.table.Put(key, value)
+	case opDelete:
+		t.table.Delete(key)
+		return nil
+	}
+	return fmt.Errorf("trie: unknown log record type %d", op)
+}
+
+// Put appends the key-value pair to the log and then puts it to the symbol
+// table. A nil value deletes the key. If key is empty this function will
+// silently return. It returns an *AlphabetError if key has characters
+// outside the alphabet, and the error of the codec or the file system if
+// the write could not be logged.
+func (t *DurableSymbolTable) Put(key string, value interface{}) error {
+	if value == nil {
+		return t.Delete(key)
+	}
+	norm := t.table.normalized(key)
+	if norm == "" {
+		return nil
+	}
+	if _, err := toIndices(t.table.Alphabet(), norm); err != nil {
+		return err
+	}
+	data, err := t.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := t.log(opPut, key, data); err != nil {
+		return err
+	}
+	t.table.Put(key, value)
+	t.compactIfDue()
+	return nil
+}
+
+// Delete appends the deletion of key to the log and then deletes key from
+// the symbol table. It returns the error of the file system if the deletion
+// could not be logged.
+func (t *DurableSymbolTable) Delete(key string) error {
+	if !t.table.Contains(key) {
+		return nil
+	}
+	if err := t.log(opDelete, key, nil); err != nil {
+		return err
+	}
+	t.table.Delete(key)
+	t.compactIfDue()
+	return nil
+}
+
+// appends a record to the log and syncs it to the disk. If the record cannot
+// be written, it is removed from the log, and if that fails too, no more
+// records are written.
+func (t *DurableSymbolTable) log(op byte, key string, value []byte) error {
+	if t.failed != nil {
+		return t.failed
+	}
+	offset, err := t.wal.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	err = writeRecord(t.wal, op, key, value)
+	if err == nil {
+		err = t.wal.Sync()
+	}
+	if err != nil {
+		// do not leave a partial record for the next records to follow
+		if terr := t.rollback(offset); terr != nil {
+			t.failed = fmt.Errorf("trie: log is in an unknown state: %w", terr)
+			return errors.Join(err, t.failed)
+		}
+		return err
+	}
+	t.records++
+	return nil
+}
+
+// removes the log after offset
+func (t *DurableSymbolTable) rollback(offset int64) error {
+	if err := t.wal.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := t.wal.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	return t.wal.Sync()
+}
+
+// compacts the log if it has CompactAfter records and reports an error to
+// OnCompactError
+func (t *DurableSymbolTable) compactIfDue() {
+	if t.CompactAfter <= 0 || t.records < t.CompactAfter {
+		return
+	}
+	if err := t.Compact(); err != nil && t.OnCompactError != nil {
+		t.OnCompactError(err)
+	}
+}
+
+// Compact writes the key-value pairs of the symbol table to a new snapshot
+// file and empties the log.
+func (t *DurableSymbolTable) Compact() error {
+	tmp, err := os.CreateTemp(t.dir, snapshotFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for key, value := range t.table.All() {
+		data, err := t.codec.Marshal(value)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := writeRecord(w, opPut, key, data); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(t.dir, snapshotFile)); err != nil {
+		return err
+	}
+	if err := syncDir(t.dir); err != nil {
+		return err
+	}
+	// the records of the log are in the snapshot now
+	if err := t.wal.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := t.wal.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	t.records = 0
+	return t.wal.Sync()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Close closes the log file. The symbol table must not be written to after
+// it is closed.
+func (t *DurableSymbolTable) Close() error {
+	return t.wal.Close()
+}
+
+// Get returns the value associated with key, or nil if key is not found.
+func (t *DurableSymbolTable) Get(key string) interface{} {
+	return t.table.Get(key)
+}
+
+// Contains returns true if the symbol table contains key and false
+// otherwise.
+func (t *DurableSymbolTable) Contains(key string) bool {
+	return t.table.Contains(key)
+}
+
+// Len returns the number of keys in the symbol table.
+func (t *DurableSymbolTable) Len() int {
+	return t.table.Len()
+}
+
+// Snapshot returns a copy of the symbol table in constant time, for reading
+// the keys with the functions of SymbolTable. The writes to the copy are not
+// logged.
+func (t *DurableSymbolTable) Snapshot() *SymbolTable {
+	return t.table.Snapshot()
+}
+
+// writes a record of the operation op of the key-value pair to w
+func writeRecord(w io.Writer, op byte, key string, value []byte) error {
+	payload := make([]byte, 0, 1+binary.MaxVarintLen64+len(key)+len(value))
+	payload = append(payload, op)
+	payload = binary.AppendUvarint(payload, uint64(len(key)))
+	payload = append(payload, key...)
+	payload = append(payload, value...)
+	var header [recordHeader]byte
+	binary.LittleEndian.PutUint32(header[:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(payload))
+	binary.LittleEndian.PutUint32(header[8:], crc32.ChecksumIEEE(header[:8]))
+	_, err := w.Write(append(header[:], payload...))
+	return err
+}
+
+// reads a record from r and returns its operation, key-value pair and
+// length. It returns io.EOF if r has no more records, io.ErrUnexpectedEOF if
+// r ends in the middle of the record, and another error if the record is
+// invalid. The header has its own checksum, so that a corrupt length is not
+// mistaken for a record that r ends in the middle of.
+func readRecord(r *bufio.Reader) (op byte, key string, value []byte, n int, err error) {
+	var header [recordHeader]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return 0, "", nil, 0, io.EOF
+		}
+		return 0, "", nil, 0, io.ErrUnexpectedEOF
+	}
+	if crc32.ChecksumIEEE(header[:8]) != binary.LittleEndian.Uint32(header[8:]) {
+		return 0, "", nil, 0, errors.New("header checksum mismatch")
+	}
+	length := binary.LittleEndian.Uint32(header[:4])
+	if length == 0 || length > maxRecord {
+		return 0, "", nil, 0, errors.New("invalid record length")
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, "", nil, 0, io.ErrUnexpectedEOF
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:8]) {
+		return 0, "", nil, 0, errors.New("checksum mismatch")
+	}
+	keyLen, k := binary.Uvarint(payload[1:])
+	if k <= 0 || keyLen > uint64(len(payload)-1-k) {
+		return 0, "", nil, 0, errors.New("invalid key length")
+	}
+	n = recordHeader + int(length)
+	start := 1 + k
+	end := start + int(keyLen)
+	return payload[0], string(payload[start:end]), payload[end:], n, nil
+}
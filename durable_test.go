@@ -0,0 +1,148 @@
+package trie_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+func TestDurableSymbolTableReplay(t *testing.T) {
+	dir := t.TempDir()
+	st, err := trie.OpenDurableSymbolTable(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, w := range data {
+		if err := st.Put(w, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st.Delete("by")
+	st.Put("the", "end")
+	st.Close()
+
+	st, err = trie.OpenDurableSymbolTable(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if st.Len() != 6 || st.Get("sea") != 6 || st.Get("the") != "end" || st.Contains("by") {
+		t.Errorf("expected replayed table, but got %v", st.Snapshot().Keys())
+	}
+}
+
+func TestDurableSymbolTableTornTail(t *testing.T) {
+	dir := t.TempDir()
+	st, err := trie.OpenDurableSymbolTable(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Put("she", 1)
+	st.Put("sells", 2)
+	st.Close()
+	wal := filepath.Join(dir, "wal")
+	info, _ := os.Stat(wal)
+	// cut the last record in the middle
+	if err := os.Truncate(wal, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err = trie.OpenDurableSymbolTable(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Len() != 1 || st.Get("she") != 1 {
+		t.Errorf("expected only 'she', but got %v", st.Snapshot().Keys())
+	}
+	st.Put("sea", 3)
+	st.Close()
+
+	st, err = trie.OpenDurableSymbolTable(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if keys := st.Snapshot().Keys(); !slices.Equal(keys, []string{"sea", "she"}) {
+		t.Errorf("expected [sea she], but got %v", keys)
+	}
+}
+
+func TestDurableSymbolTableCorruptLog(t *testing.T) {
+	dir := t.TempDir()
+	st, err := trie.OpenDurableSymbolTable(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Put("she", 1)
+	st.Put("sells", 2)
+	st.Put("sea", 3)
+	st.Close()
+	wal := filepath.Join(dir, "wal")
+	b, err := os.ReadFile(wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// flip a byte of the length, of the checksum and of the payload of the
+	// first record
+	for _, i := range []int{1, 5, 14} {
+		corrupt := bytes.Clone(b)
+		corrupt[i] ^= 0xff
+		if err := os.WriteFile(wal, corrupt, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := trie.OpenDurableSymbolTable(dir); !errors.Is(err, trie.ErrCorrupt) {
+			t.Errorf("expected '%v' for byte %d, but got '%v'", trie.ErrCorrupt, i, err)
+		}
+		if info, _ := os.Stat(wal); info.Size() != int64(len(b)) {
+			t.Errorf("expected log of %d bytes, but got %d", len(b), info.Size())
+		}
+	}
+}
+
+func TestDurableSymbolTableCompact(t *testing.T) {
+	dir := t.TempDir()
+	st, err := trie.OpenDurableSymbolTable(dir, trie.WithNormalizer(trie.FoldCase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.CompactAfter = 10
+	for i := 0; i < 25; i++ {
+		st.Put("Key"+strconv.Itoa(i%12), i)
+	}
+	st.Delete("key0")
+	info, _ := os.Stat(filepath.Join(dir, "wal"))
+	if info.Size() == 0 {
+		t.Errorf("expected log to have the writes after the last compaction")
+	}
+	st.Close()
+
+	st, err = trie.OpenDurableSymbolTable(dir, trie.WithNormalizer(trie.FoldCase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Len() != 11 || st.Get("key11") != 23 || st.Get("KEY1") != 13 || st.Contains("key0") {
+		t.Errorf("expected 11 keys, but got %v", st.Snapshot().Keys())
+	}
+	if err := st.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+	if info, _ := os.Stat(filepath.Join(dir, "wal")); info.Size() != 0 {
+		t.Errorf("expected empty log after compaction, but got %d bytes", info.Size())
+	}
+
+	st, err = trie.OpenDurableSymbolTable(dir, trie.WithNormalizer(trie.FoldCase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if keys := st.Snapshot().KeysWithPrefix("key1"); !slices.Equal(keys, []string{"Key1", "Key10", "Key11"}) {
+		t.Errorf("expected [Key1 Key10 Key11], but got %v", keys)
+	}
+}
@@ -0,0 +1,200 @@
+package trie // import "kkn.fi/trie"
+
+import (
+	"container/heap"
+	"time"
+)
+
+type (
+	expiringValue struct {
+		value   interface{}
+		expires time.Time // zero if the value never expires
+	}
+	expiration struct {
+		key   string
+		entry *expiringValue
+	}
+	// expirationHeap is a min-heap of expirations by their time.
+	expirationHeap []expiration
+	// ExpiringSymbolTable is a SymbolTable whose entries can expire. An
+	// expired entry is invisible to all of the functions, and it is deleted
+	// from the underlying trie, pruning the nodes that become empty, by the
+	// next function call. Purge deletes the expired entries without
+	// otherwise accessing the table, for example periodically to release
+	// the memory of entries that are no longer accessed.
+	//
+	// The current time is read from the clock set with WithClock, so the
+	// expiration can be tested deterministically.
+	//
+	// Every function, including the reads such as Get and Keys, purges the
+	// expired entries first, so every function writes to the table. The
+	// table does no locking, and goroutines that share it must synchronize
+	// all of their calls like writes, for example with a sync.Mutex, not a
+	// sync.RWMutex.
+	ExpiringSymbolTable struct {
+		table       *SymbolTable // values are *expiringValue
+		expirations expirationHeap
+		now         func() time.Time
+	}
+)
+
+// NewExpiringSymbolTable returns an empty expiring symbol table.
+func NewExpiringSymbolTable(opts ...Option) *ExpiringSymbolTable {
+	c := newConfig(opts)
+	return &ExpiringSymbolTable{
+		table: NewSymbolTable(opts...),
+		now:   c.clock,
+	}
+}
+
+// Put puts the key-value pair to the table without an expiration time. A nil
+// value deletes the key. If key is empty this function will silently return.
+// It returns an *AlphabetError if key has characters outside the alphabet.
+func (t *ExpiringSymbolTable) Put(key string, value interface{}) error {
+	t.Purge()
+	if value == nil {
+		t.table.Delete(key)
+		return nil
+	}
+	return t.table.Put(key, &expiringValue{value: value})
+}
+
+// PutWithTTL puts the key-value pair to the table for the duration ttl,
+// after which the key expires. A nil value or a ttl of zero or less deletes
+// the key. If key is empty this function will silently return. It returns an
+// *AlphabetError if key has characters outside the alphabet.
+func (t *ExpiringSymbolTable) PutWithTTL(key string, value interface{}, ttl time.Duration) error {
+	t.Purge()
+	if value == nil || ttl <= 0 {
+		t.table.Delete(key)
+		return nil
+	}
+	entry := &expiringValue{value: value, expires: t.now().Add(ttl)}
+	if err := t.table.Put(key, entry); err != nil || t.table.normalized(key) == "" {
+		return err
+	}
+	// drop the expirations of the overwritten entries if they dominate
+	if len(t.expirations) > 2*t.table.Len() {
+		t.compact()
+	}
+	heap.Push(&t.expirations, expiration{key: key, entry: entry})
+	return nil
+}
+
+// compact removes the expirations of the entries that are no longer in the
+// table.
+func (t *ExpiringSymbolTable) compact() {
+	live := t.expirations[:0]
+	for _, e := range t.expirations {
+		if t.table.Get(e.key) == e.entry {
+			live = append(live, e)
+		}
+	}
+	clear(t.expirations[len(live):])
+	t.expirations = live
+	heap.Init(&t.expirations)
+}
+
+// Purge deletes the expired entries from the table and returns the number of
+// entries deleted.
+func (t *ExpiringSymbolTable) Purge() int {
+	n := 0
+	now := t.now()
+	for len(t.expirations) > 0 && !t.expirations[0].entry.expires.After(now) {
+		e := heap.Pop(&t.expirations).(expiration)
+		// the key may have been overwritten or deleted since
+		if t.table.Get(e.key) == e.entry {
+			t.table.Delete(e.key)
+			n++
+		}
+	}
+	return n
+}
+
+// Get returns the value associated with key, or nil if key is not found or
+// it has expired.
+func (t *ExpiringSymbolTable) Get(key string) interface{} {
+	t.Purge()
+	if e, ok := t.table.Get(key).(*expiringValue); ok {
+		return e.value
+	}
+	return nil
+}
+
+// ExpiresAt returns the time when key expires. It returns false if key is
+// not found or it never expires.
+func (t *ExpiringSymbolTable) ExpiresAt(key string) (time.Time, bool) {
+	t.Purge()
+	if e, ok := t.table.Get(key).(*expiringValue); ok && !e.expires.IsZero() {
+		return e.expires, true
+	}
+	return time.Time{}, false
+}
+
+// Contains returns true if the table contains key and it has not expired.
+func (t *ExpiringSymbolTable) Contains(key string) bool {
+	t.Purge()
+	return t.table.Contains(key)
+}
+
+// Delete removes the key from the table if the key is present.
+func (t *ExpiringSymbolTable) Delete(key string) {
+	t.Purge()
+	t.table.Delete(key)
+}
+
+// Keys returns all the keys in the table that have not expired.
+func (t *ExpiringSymbolTable) Keys() []string {
+	t.Purge()
+	return t.table.Keys()
+}
+
+// KeysWithPrefix returns the keys in the table that start with prefix and
+// have not expired.
+func (t *ExpiringSymbolTable) KeysWithPrefix(prefix string) []string {
+	t.Purge()
+	return t.table.KeysWithPrefix(prefix)
+}
+
+// LongestPrefixOf returns the key in the table that is the longest prefix of
+// query and has not expired, or empty string, if no such key is found.
+func (t *ExpiringSymbolTable) LongestPrefixOf(query string) string {
+	t.Purge()
+	return t.table.LongestPrefixOf(query)
+}
+
+// KeysThatMatch returns the keys in the table that match pattern and have
+// not expired, where '.' symbol is treated as a wildcard character.
+func (t *ExpiringSymbolTable) KeysThatMatch(pattern string) []string {
+	t.Purge()
+	return t.table.KeysThatMatch(pattern)
+}
+
+// Len returns the number of keys in the table that have not expired.
+func (t *ExpiringSymbolTable) Len() int {
+	t.Purge()
+	return t.table.Len()
+}
+
+// IsEmpty returns true if the table has no keys that have not expired.
+func (t *ExpiringSymbolTable) IsEmpty() bool {
+	return t.Len() == 0
+}
+
+func (h expirationHeap) Len() int { return len(h) }
+
+func (h expirationHeap) Less(i, j int) bool {
+	return h[i].entry.expires.Before(h[j].entry.expires)
+}
+
+func (h expirationHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *expirationHeap) Push(x interface{}) { *h = append(*h, x.(expiration)) }
+
+func (h *expirationHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	old[len(old)-1] = expiration{}
+	*h = old[:len(old)-1]
+	return x
+}
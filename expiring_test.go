@@ -0,0 +1,99 @@
+package trie_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"kkn.fi/trie"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestExpiringSymbolTable(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	st := trie.NewExpiringSymbolTable(trie.WithClock(clock.Now))
+	st.PutWithTTL("she", 1, time.Minute)
+	st.PutWithTTL("shells", 2, 2*time.Minute)
+	st.PutWithTTL("shore", 3, 3*time.Minute)
+	st.Put("sea", 4)
+	if st.Len() != 4 || st.Get("she") != 1 {
+		t.Errorf("expected length 4 and value 1, but got %d and %v", st.Len(), st.Get("she"))
+	}
+	if expires, ok := st.ExpiresAt("shells"); !ok || !expires.Equal(time.Unix(120, 0)) {
+		t.Errorf("expected expiration at %v, but got %v", time.Unix(120, 0), expires)
+	}
+	clock.Advance(time.Minute)
+	if st.Contains("she") || st.Get("she") != nil {
+		t.Errorf("expected 'she' to have expired, but got %v", st.Get("she"))
+	}
+	if keys := st.KeysWithPrefix("sh"); !slices.Equal(keys, []string{"shells", "shore"}) {
+		t.Errorf("expected [shells shore], but got %v", keys)
+	}
+	// refreshing a key replaces its expiration time
+	st.PutWithTTL("shells", 5, 10*time.Minute)
+	clock.Advance(2 * time.Minute)
+	if keys := st.Keys(); !slices.Equal(keys, []string{"sea", "shells"}) {
+		t.Errorf("expected [sea shells], but got %v", keys)
+	}
+	clock.Advance(time.Hour)
+	if n := st.Purge(); n != 1 {
+		t.Errorf("expected 1 purged key, but got %d", n)
+	}
+	if st.Len() != 1 || st.Get("sea") != 4 {
+		t.Errorf("expected only 'sea' left, but got %v", st.Keys())
+	}
+	if _, ok := st.ExpiresAt("sea"); ok {
+		t.Errorf("expected 'sea' to never expire")
+	}
+}
+
+func TestExpiringSymbolTableOverwrite(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	st := trie.NewExpiringSymbolTable(trie.WithClock(clock.Now))
+	for i := 0; i < 100; i++ {
+		st.PutWithTTL("sea", i, time.Duration(i+1)*time.Second)
+	}
+	st.Put("she", 1)
+	st.PutWithTTL("she", 2, time.Second)
+	st.Put("she", 3)
+	clock.Advance(50 * time.Second)
+	if st.Get("sea") != 99 || st.Get("she") != 3 {
+		t.Errorf("expected values 99 and 3, but got %v and %v", st.Get("sea"), st.Get("she"))
+	}
+	clock.Advance(50 * time.Second)
+	if st.Contains("sea") || st.Len() != 1 {
+		t.Errorf("expected 'sea' to have expired, but got %v", st.Keys())
+	}
+}
+
+func TestExpiringSymbolTablePrefixAndMatch(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	st := trie.NewExpiringSymbolTable(trie.WithClock(clock.Now))
+	st.Put("she", 1)
+	st.PutWithTTL("shells", 2, time.Minute)
+	st.PutWithTTL("shore", 3, 2*time.Minute)
+	if prefix := st.LongestPrefixOf("shellsort"); prefix != "shells" {
+		t.Errorf("expected 'shells', but got '%v'", prefix)
+	}
+	if keys := st.KeysThatMatch("sh..e"); !slices.Equal(keys, []string{"shore"}) {
+		t.Errorf("expected [shore], but got %v", keys)
+	}
+	clock.Advance(2 * time.Minute)
+	if prefix := st.LongestPrefixOf("shellsort"); prefix != "she" {
+		t.Errorf("expected 'she', but got '%v'", prefix)
+	}
+	if keys := st.KeysThatMatch("sh..e"); len(keys) != 0 {
+		t.Errorf("expected no keys, but got %v", keys)
+	}
+}
@@ -0,0 +1,3 @@
+module kkn.fi/trie
+
+go 1.23
@@ -0,0 +1,145 @@
+package trie // import "kkn.fi/trie"
+
+import "container/list"
+
+type (
+	lruEntry struct {
+		key   string
+		value interface{}
+	}
+	// LRUSymbolTable is a SymbolTable with a capacity, which makes it usable
+	// as a prefix-aware cache. When a put would exceed the capacity, the
+	// least recently used key is evicted. A key is used when it is put, and
+	// when it is found by Get or LongestPrefixOf. The other functions do not
+	// change the recency of the keys.
+	LRUSymbolTable struct {
+		// OnEvict is called with the key-value pair of each evicted key, if
+		// it is not nil. It is not called for deleted keys.
+		OnEvict func(key string, value interface{})
+
+		table    *SymbolTable // values are *list.Element of *lruEntry
+		recency  *list.List   // from the most to the least recently used
+		capacity int
+	}
+)
+
+// NewLRUSymbolTable returns an empty symbol table that holds at most capacity
+// keys. A capacity of zero or less means no limit.
+func NewLRUSymbolTable(capacity int, opts ...Option) *LRUSymbolTable {
+	return &LRUSymbolTable{
+		table:    NewSymbolTable(opts...),
+		recency:  list.New(),
+		capacity: capacity,
+	}
+}
+
+// Capacity returns the greatest number of keys in the table.
+func (t *LRUSymbolTable) Capacity() int {
+	return t.capacity
+}
+
+// Put puts the key-value pair to the table and makes key the most recently
+// used one, evicting the least recently used key if the table is full. A nil
+// value deletes the key. If key is empty this function will silently return.
+// It returns an *AlphabetError if key has characters outside the alphabet.
+func (t *LRUSymbolTable) Put(key string, value interface{}) error {
+	if value == nil {
+		t.Delete(key)
+		return nil
+	}
+	if e := t.element(key); e != nil {
+		entry := e.Value.(*lruEntry)
+		entry.key, entry.value = key, value
+		t.recency.MoveToFront(e)
+		return t.table.Put(key, e)
+	}
+	e := t.recency.PushFront(&lruEntry{key: key, value: value})
+	if err := t.table.Put(key, e); err != nil || t.table.normalized(key) == "" {
+		t.recency.Remove(e)
+		return err
+	}
+	if t.capacity > 0 && t.recency.Len() > t.capacity {
+		t.evict()
+	}
+	return nil
+}
+
+// evicts the least recently used key
+func (t *LRUSymbolTable) evict() {
+	entry := t.recency.Remove(t.recency.Back()).(*lruEntry)
+	t.table.Delete(entry.key)
+	if t.OnEvict != nil {
+		t.OnEvict(entry.key, entry.value)
+	}
+}
+
+// returns the list element of key, or nil if key is not found
+func (t *LRUSymbolTable) element(key string) *list.Element {
+	if e, ok := t.table.Get(key).(*list.Element); ok {
+		return e
+	}
+	return nil
+}
+
+// Get returns the value associated with key and makes key the most recently
+// used one. It returns nil if key is not found.
+func (t *LRUSymbolTable) Get(key string) interface{} {
+	e := t.element(key)
+	if e == nil {
+		return nil
+	}
+	t.recency.MoveToFront(e)
+	return e.Value.(*lruEntry).value
+}
+
+// Peek returns the value associated with key without changing the recency of
+// key. It returns nil if key is not found.
+func (t *LRUSymbolTable) Peek(key string) interface{} {
+	if e := t.element(key); e != nil {
+		return e.Value.(*lruEntry).value
+	}
+	return nil
+}
+
+// Contains returns true if the table contains key and false otherwise.
+func (t *LRUSymbolTable) Contains(key string) bool {
+	return t.table.Contains(key)
+}
+
+// Delete removes the key from the table if the key is present.
+func (t *LRUSymbolTable) Delete(key string) {
+	if e := t.element(key); e != nil {
+		t.recency.Remove(e)
+		t.table.Delete(key)
+	}
+}
+
+// LongestPrefixOf returns the longest key in the table that is a prefix of
+// query, and makes the key the most recently used one.
+func (t *LRUSymbolTable) LongestPrefixOf(query string) string {
+	match := t.table.LongestPrefixOf(query)
+	if e := t.element(match); e != nil {
+		t.recency.MoveToFront(e)
+	}
+	return match
+}
+
+// KeysWithPrefix returns all keys in the table starting with prefix.
+func (t *LRUSymbolTable) KeysWithPrefix(prefix string) []string {
+	return t.table.KeysWithPrefix(prefix)
+}
+
+// Keys returns all the keys in the table.
+func (t *LRUSymbolTable) Keys() []string {
+	return t.table.Keys()
+}
+
+// Len returns the number of keys in the table.
+func (t *LRUSymbolTable) Len() int {
+	return t.table.Len()
+}
+
+// IsEmpty returns true if the table is empty.
+func (t *LRUSymbolTable) IsEmpty() bool {
+	return t.table.IsEmpty()
+}
@@ -0,0 +1,59 @@
+package trie_test
+
+import (
+	"slices"
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+func TestLRUSymbolTable(t *testing.T) {
+	st := trie.NewLRUSymbolTable(3)
+	var evicted []string
+	st.OnEvict = func(key string, value interface{}) {
+		evicted = append(evicted, key)
+	}
+	st.Put("she", 1)
+	st.Put("sells", 2)
+	st.Put("sea", 3)
+	st.Get("she")
+	st.Put("shells", 4)
+	if !slices.Equal(evicted, []string{"sells"}) {
+		t.Errorf("expected [sells] to be evicted, but got %v", evicted)
+	}
+	if match := st.LongestPrefixOf("seashore"); match != "sea" {
+		t.Errorf("expected 'sea', but got '%v'", match)
+	}
+	st.Peek("she")
+	st.Put("shore", 5)
+	if !slices.Equal(evicted, []string{"sells", "she"}) {
+		t.Errorf("expected [sells she] to be evicted, but got %v", evicted)
+	}
+	st.Put("sea", 6)
+	st.Delete("shells")
+	st.Put("by", 7)
+	st.Put("the", 8)
+	if !slices.Equal(evicted, []string{"sells", "she", "shore"}) {
+		t.Errorf("expected [sells she shore] to be evicted, but got %v", evicted)
+	}
+	if keys := st.Keys(); !slices.Equal(keys, []string{"by", "sea", "the"}) || st.Len() != 3 {
+		t.Errorf("expected [by sea the], but got %v", keys)
+	}
+	if st.Get("sea") != 6 || st.Contains("shore") {
+		t.Errorf("expected value 6 and no 'shore', but got %v and %v", st.Get("sea"), st.Contains("shore"))
+	}
+}
+
+func TestLRUSymbolTableUnbounded(t *testing.T) {
+	st := trie.NewLRUSymbolTable(0, trie.WithNormalizer(trie.FoldCase))
+	for i, w := range data {
+		st.Put(w, i)
+	}
+	st.Put("SEA", 8)
+	if st.Len() != 7 || st.Get("sea") != 8 {
+		t.Errorf("expected length 7 and value 8, but got %d and %v", st.Len(), st.Get("sea"))
+	}
+	if keys := st.KeysWithPrefix("se"); !slices.Equal(keys, []string{"SEA", "sells"}) {
+		t.Errorf("expected [SEA sells], but got %v", keys)
+	}
+}
@@ -0,0 +1,222 @@
+package trie // import "kkn.fi/trie"
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+)
+
+type (
+	// Proof proves that a key is or is not in a SymbolTable with a given
+	// root hash. It has the contents of the nodes on the path of the key
+	// from the root, except for the hashes of the children on the path,
+	// which the verifier computes from the nodes below them.
+	Proof struct {
+		Nodes []ProofNode
+	}
+	// ProofNode is a node on the path of a Proof.
+	ProofNode struct {
+		Value    []byte       // hash of the value of the node, or nil
+		Children []ProofChild // children of the node that are not on the path
+	}
+	// ProofChild is a child of a ProofNode.
+	ProofChild struct {
+		Index int    // index of the character of the child in the alphabet
+		Hash  []byte // hash of the subtrie rooted at the child
+	}
+)
+
+// RootHash returns the Merkle hash of the symbol table. Two symbol tables
+// with the same alphabet have the same hash if and only if they have the same
+// keys and values, up to SHA-256 collisions. Each node caches the hash of its
+// subtrie, and a write clears the hashes on the path of the written key
+// only, so the hash is updated in time proportional to the number of nodes
+// written to.
+//
+// A value is hashed by its type and its Go syntax, as printed with the %T
+// and %#v verbs of fmt, so that values whose String or Error methods return
+// the same string have different hashes. The pointers inside of a value
+// are printed as addresses instead of the values they point to, so values
+// should not contain pointers other than a pointer to a struct.
+//
+// The nodes shared with snapshots are not written to, so their hashes are
+// cached too, once computed by any of the symbol tables that share them. The
+// hashes are stored atomically, so the hashes of snapshots can be computed
+// concurrently.
+func (t *SymbolTable) RootHash() []byte {
+	return bytes.Clone(t.hash(t.root))
+}
+
+// PrefixHash returns the Merkle hash of the keys that start with prefix and
+// their values. It is the hash of an empty symbol table if there are no such
+// keys. Replicas can compare the hashes of prefixes to find the subtries in
+// which they differ.
+func (t *SymbolTable) PrefixHash(prefix string) []byte {
+	x := t.get(t.root, t.normalized(prefix))
+	return bytes.Clone(t.hash(x))
+}
+
+// Prove returns a proof of whether key is in the symbol table, which can be
+// verified with VerifyProof against the root hash.
+// It returns an *AlphabetError if key has characters outside the alphabet.
+func (t *SymbolTable) Prove(key string) (*Proof, error) {
+	indices, err := toIndices(t.Alphabet(), t.normalized(key))
+	if err != nil {
+		return nil, err
+	}
+	p := new(Proof)
+	x := t.root
+	for d := 0; ; d++ {
+		var n ProofNode
+		if x != nil {
+			if x.value != nil {
+				n.Value = hashValue(x.value)
+			}
+			for i, next := range x.next {
+				if next != nil && (d == len(indices) || i != indices[d]) {
+					n.Children = append(n.Children, ProofChild{Index: i, Hash: bytes.Clone(t.hash(next))})
+				}
+			}
+		}
+		p.Nodes = append(p.Nodes, n)
+		if x == nil || d == len(indices) || x.next[indices[d]] == nil {
+			return p, nil
+		}
+		x = x.next[indices[d]]
+	}
+}
+
+// VerifyProof returns true if proof proves that the symbol table with the
+// hash root has the key-value pair, or that it does not have key if value
+// is nil. The symbol table must have been constructed with the alphabet and
+// the normalizers of opts.
+func VerifyProof(root []byte, key string, value interface{}, proof *Proof, opts ...Option) bool {
+	c := newConfig(opts)
+	if c.normalize != nil {
+		key = c.normalize(key)
+	}
+	indices, err := toIndices(c.alphabet, key)
+	if err != nil || proof == nil || len(proof.Nodes) == 0 || len(proof.Nodes) > len(indices)+1 {
+		return false
+	}
+	last := proof.Nodes[len(proof.Nodes)-1]
+	switch {
+	case len(proof.Nodes) <= len(indices):
+		// the path ends before key, so the child on the path must be nil
+		d := len(proof.Nodes) - 1
+		if value != nil || hasChild(last, indices[d]) {
+			return false
+		}
+	case value == nil:
+		if last.Value != nil {
+			return false
+		}
+	default:
+		if !bytes.Equal(last.Value, hashValue(value)) {
+			return false
+		}
+	}
+	for _, n := range proof.Nodes {
+		if !wellFormed(n, c.alphabet.Radix()) {
+			return false
+		}
+	}
+	h := sha256.New()
+	sum := hashNode(h, last.Value, last.Children, -1, nil)
+	for d := len(proof.Nodes) - 2; d >= 0; d-- {
+		n := proof.Nodes[d]
+		if hasChild(n, indices[d]) {
+			return false
+		}
+		sum = hashNode(h, n.Value, n.Children, indices[d], sum)
+	}
+	return bytes.Equal(sum, root)
+}
+
+// wellFormed returns true if the hashes of n have the size of a hash and its
+// children are in increasing order, so that the bytes hashed for n cannot be
+// parsed as a different node.
+func wellFormed(n ProofNode, radix int) bool {
+	if n.Value != nil && len(n.Value) != sha256.Size {
+		return false
+	}
+	prev := -1
+	for _, c := range n.Children {
+		if c.Index <= prev || c.Index >= radix || len(c.Hash) != sha256.Size {
+			return false
+		}
+		prev = c.Index
+	}
+	return true
+}
+
+func hasChild(n ProofNode, i int) bool {
+	for _, c := range n.Children {
+		if c.Index == i {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the hash of the subtrie rooted at x, computing and caching the
+// hashes of the nodes that have none
+func (t *SymbolTable) hash(x *sTNode) []byte {
+	if x == nil {
+		return hashNode(sha256.New(), nil, nil, -1, nil)
+	}
+	if h := x.cachedHash(); h != nil {
+		return h
+	}
+	var value []byte
+	if x.value != nil {
+		value = hashValue(x.value)
+	}
+	var children []ProofChild
+	for i, next := range x.next {
+		if next != nil {
+			children = append(children, ProofChild{Index: i, Hash: t.hash(next)})
+		}
+	}
+	sum := hashNode(sha256.New(), value, children, -1, nil)
+	x.hash.Store(&sum)
+	return sum
+}
+
+// hashNode returns the hash of a node with the value hash value and the
+// children, and the child at index with the hash sum if index is not
+// negative. The children must be in the order of their indices.
+func hashNode(h hash.Hash, value []byte, children []ProofChild, index int, sum []byte) []byte {
+	h.Reset()
+	var buf [binary.MaxVarintLen64]byte
+	if value == nil {
+		h.Write([]byte{0})
+	} else {
+		h.Write([]byte{1})
+		h.Write(value)
+	}
+	child := func(i int, sum []byte) {
+		h.Write(binary.AppendUvarint(buf[:0], uint64(i)))
+		h.Write(sum)
+	}
+	for _, c := range children {
+		if index >= 0 && index < c.Index {
+			child(index, sum)
+			index = -1
+		}
+		child(c.Index, c.Hash)
+	}
+	if index >= 0 {
+		child(index, sum)
+	}
+	return h.Sum(nil)
+}
+
+// returns the hash of a value
+func hashValue(value interface{}) []byte {
+	h := sha256.New()
+	fmt.Fprintf(h, "%T:%#v", value, value)
+	return h.Sum(nil)
+}
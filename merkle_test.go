@@ -0,0 +1,196 @@
+package trie_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+func TestSymbolTableRootHash(t *testing.T) {
+	a := trie.NewSymbolTable()
+	b := trie.NewSymbolTable()
+	empty := a.RootHash()
+	for i, w := range data {
+		a.Put(w, i)
+	}
+	for i := len(data) - 1; i >= 0; i-- {
+		b.Put(data[i], i)
+	}
+	if bytes.Equal(a.RootHash(), b.RootHash()) {
+		t.Errorf("expected different hashes for different values of 'sea'")
+	}
+	b.Put("sea", 6)
+	if !bytes.Equal(a.RootHash(), b.RootHash()) {
+		t.Errorf("expected equal hashes for equal tables")
+	}
+	snapshot := a.Snapshot()
+	hash := snapshot.RootHash()
+	a.Put("shell", 8)
+	if bytes.Equal(a.RootHash(), hash) || !bytes.Equal(snapshot.RootHash(), hash) {
+		t.Errorf("expected only the written table to change its hash")
+	}
+	if !bytes.Equal(a.PrefixHash("se"), b.PrefixHash("se")) || bytes.Equal(a.PrefixHash("sh"), b.PrefixHash("sh")) {
+		t.Errorf("expected prefix hashes to differ only under 'sh'")
+	}
+	a.Delete("shell")
+	if !bytes.Equal(a.RootHash(), hash) {
+		t.Errorf("expected the hash before the write after deleting the written key")
+	}
+	for _, w := range data {
+		a.Delete(w)
+	}
+	if !bytes.Equal(a.RootHash(), empty) || !bytes.Equal(a.PrefixHash("x"), empty) {
+		t.Errorf("expected the hash of an empty table")
+	}
+}
+
+func TestSymbolTableRootHashValues(t *testing.T) {
+	a := trie.NewSymbolTable()
+	b := trie.NewSymbolTable()
+	a.Put("sea", label{1})
+	b.Put("sea", label{2})
+	if bytes.Equal(a.RootHash(), b.RootHash()) {
+		t.Errorf("expected different hashes for values with the same String")
+	}
+	a.Put("sea", errors.New("e"))
+	b.Put("sea", fmt.Errorf("e"))
+	if !bytes.Equal(a.RootHash(), b.RootHash()) {
+		t.Errorf("expected equal hashes for equal errors")
+	}
+}
+
+func TestSymbolTableRootHashSnapshots(t *testing.T) {
+	st := trie.NewSymbolTable()
+	for i, w := range data {
+		st.Put(w, i)
+	}
+	a := st.Snapshot()
+	st.Put("shell", 8)
+	b := st.Snapshot()
+	// the snapshots share nodes without cached hashes
+	expected := [][]byte{a.Clone().RootHash(), b.Clone().RootHash()}
+	var wg sync.WaitGroup
+	for i, s := range []*trie.SymbolTable{a, b} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if hash := s.RootHash(); !bytes.Equal(hash, expected[i]) {
+				t.Errorf("expected '%x', but got '%x'", expected[i], hash)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSymbolTableRootHashIncremental(t *testing.T) {
+	st := trie.NewSymbolTable()
+	for i := 0; i < 10000; i++ {
+		st.Put(strconv.Itoa(i), i)
+	}
+	st.Begin().Rollback()
+	st.RootHash()
+	i := 0
+	// a full rehash would allocate for each of the 10000 keys
+	allocs := testing.AllocsPerRun(100, func() {
+		st.Snapshot()
+		st.Put(strconv.Itoa(i), -i)
+		st.RootHash()
+		i++
+	})
+	if allocs > 1000 {
+		t.Errorf("expected hashing of the written path only, but got %v allocations", allocs)
+	}
+}
+
+func BenchmarkSymbolTableRootHash(b *testing.B) {
+	st := trie.NewSymbolTable()
+	for i := 0; i < 100000; i++ {
+		st.Put(strconv.Itoa(i), i)
+	}
+	st.RootHash()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		st.Snapshot()
+		st.Put(strconv.Itoa(n%100000), n)
+		st.RootHash()
+	}
+}
+
+func TestSymbolTableProof(t *testing.T) {
+	st := trie.NewSymbolTable(trie.WithNormalizer(trie.FoldCase))
+	for i, w := range data {
+		st.Put(w, i)
+	}
+	root := st.RootHash()
+	td := []struct {
+		key   string
+		value interface{}
+		valid bool
+	}{
+		{"Sea", 6, true},
+		{"sea", 2, false},
+		{"sea", nil, false},
+		{"shells", 3, true},
+		{"shell", nil, true},
+		{"shell", 3, false},
+		{"shellfish", nil, true},
+		{"sh", nil, true},
+		{"x", nil, true},
+		{"", nil, true},
+	}
+	for _, test := range td {
+		proof, err := st.Prove(test.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if valid := trie.VerifyProof(root, test.key, test.value, proof, trie.WithNormalizer(trie.FoldCase)); valid != test.valid {
+			t.Errorf("expected proof of '%v' with value %v to be %v, but got %v", test.key, test.value, test.valid, valid)
+		}
+	}
+	// a proof of non-membership cannot hide a child in the hash of another
+	proof, _ := st.Prove("sh")
+	n := &proof.Nodes[1]
+	for i := range n.Children {
+		n.Children[i].Hash = append(n.Children[i].Hash, 0)
+	}
+	if trie.VerifyProof(root, "sh", nil, proof) {
+		t.Errorf("expected proof with malformed hashes to be invalid")
+	}
+	proof, _ = st.Prove("sea")
+	if trie.VerifyProof(trie.NewSymbolTable().RootHash(), "sea", 6, proof) {
+		t.Errorf("expected proof to be invalid for another root hash")
+	}
+}
+
+func TestSymbolTableRootHashRandom(t *testing.T) {
+	st := trie.NewSymbolTable()
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		key := "abc"[:1+r.Intn(3)] + string(rune('a'+r.Intn(3)))
+		switch r.Intn(4) {
+		case 0:
+			st.Put(key, r.Intn(3))
+		case 1:
+			st.Delete(key)
+		case 2:
+			st.Swap(key, nil)
+		case 3:
+			st.Update(key, func(old interface{}, exists bool) (interface{}, bool) {
+				return i % 2, exists
+			})
+		}
+		fresh := trie.NewSymbolTable()
+		for key, value := range st.All() {
+			fresh.Put(key, value)
+		}
+		if !bytes.Equal(st.RootHash(), fresh.RootHash()) {
+			t.Fatalf("expected hash of %v to equal the hash of a fresh table", st.Keys())
+		}
+	}
+}
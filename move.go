@@ -0,0 +1,121 @@
+package trie // import "kkn.fi/trie"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrPrefixOverlap is returned when a prefix is moved to a prefix that
+	// starts with it or that it starts with.
+	ErrPrefixOverlap = errors.New("trie: prefixes overlap")
+	// ErrDestinationNotEmpty is returned when a prefix is moved to a prefix
+	// that keys already start with.
+	ErrDestinationNotEmpty = errors.New("trie: destination prefix is not empty")
+)
+
+// MovePrefix moves the keys that start with from to start with to instead,
+// keeping their values. The subtrie of from is detached and grafted as the
+// subtrie of to, so the move takes time proportional to the lengths of the
+// prefixes, and not to the number of keys moved. If the symbol table is
+// normalized, the original spellings of the moved keys are rewritten, and
+// if the symbol table is watched, the moves are sent to the watchers, which
+// takes time proportional to the size of the subtrie.
+//
+// It returns an error wrapping ErrDestinationNotEmpty if a key starts with
+// to, an error wrapping ErrPrefixOverlap if from starts with to or to starts
+// with from, and an *AlphabetError if either prefix has characters outside
+// the alphabet. Moving a prefix to itself does nothing.
+func (t *SymbolTable) MovePrefix(from, to string) error {
+	a := t.Alphabet()
+	normFrom, normTo := t.normalized(from), t.normalized(to)
+	src, err := toIndices(a, normFrom)
+	if err != nil {
+		return err
+	}
+	dst, err := toIndices(a, normTo)
+	if err != nil {
+		return err
+	}
+	sub := t.nodeOf(src)
+	switch {
+	case normFrom == normTo:
+		return nil
+	case hasPrefix(src, dst) || hasPrefix(dst, src):
+		return fmt.Errorf("%w: %q and %q", ErrPrefixOverlap, from, to)
+	case sub == nil:
+		return nil
+	case t.nodeOf(dst) != nil:
+		return fmt.Errorf("%w: %q", ErrDestinationNotEmpty, to)
+	}
+	// the keys are only moved, so the length does not change
+	t.root = t.detach(t.root, src, 0)
+	if t.watched() {
+		t.visitIndices(sub, src, func(key []int, x *sTNode) {
+			t.notify(key, x.key, x.value, nil)
+		})
+	}
+	if t.normalize != nil {
+		sub = t.respell(sub, normFrom, to)
+	}
+	t.root = t.graft(t.root, dst, 0, sub)
+	t.writes++
+	if t.watched() {
+		t.visitIndices(sub, dst, func(key []int, x *sTNode) {
+			t.notify(key, x.key, nil, x.value)
+		})
+	}
+	return nil
+}
+
+// returns the node of the key with character indices key, or nil
+func (t *SymbolTable) nodeOf(key []int) *sTNode {
+	x := t.root
+	for d := 0; x != nil && d < len(key); d++ {
+		x = x.next[key[d]]
+	}
+	return x
+}
+
+// grafts the subtrie sub as the subtrie of key in the subtrie rooted at x,
+// which has no subtrie of key
+func (t *SymbolTable) graft(x *sTNode, key []int, d int, sub *sTNode) *sTNode {
+	if d == len(key) {
+		return sub
+	}
+	if x == nil {
+		x = t.newNode()
+	} else {
+		x = t.mutable(x)
+	}
+	c := key[d]
+	x.next[c] = t.graft(x.next[c], key, d+1, sub)
+	return x
+}
+
+// returns the subtrie rooted at x with the original spellings of its keys,
+// which start with from when normalized, starting with to instead
+func (t *SymbolTable) respell(x *sTNode, from, to string) *sTNode {
+	x = t.mutable(x)
+	if x.value != nil && x.key != "" {
+		x.key = to + t.suffix(x.key, from)
+	}
+	for i, next := range x.next {
+		if next != nil {
+			x.next[i] = t.respell(next, from, to)
+		}
+	}
+	return x
+}
+
+// returns the rest of key after its shortest prefix whose normalized form is
+// prefix, or the rest of the normalized key if there is no such prefix
+func (t *SymbolTable) suffix(key, prefix string) string {
+	for i := range key {
+		if i > 0 && t.normalize(key[:i]) == prefix {
+			return key[i:]
+		}
+	}
+	return strings.TrimPrefix(t.normalize(key), prefix)
+}
@@ -0,0 +1,68 @@
+package trie_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+func TestSymbolTableMovePrefix(t *testing.T) {
+	st := trie.NewSymbolTable()
+	for _, p := range []string{"/usr/bin/go", "/usr/bin/gofmt", "/usr/lib/libc", "/usr/local/bin/x"} {
+		st.Put(p, len(p))
+	}
+	snapshot := st.Snapshot()
+	events, cancel := st.Watch("/opt/")
+	defer cancel()
+	if err := st.MovePrefix("/usr/bin/", "/opt/go/bin/"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"/opt/go/bin/go", "/opt/go/bin/gofmt", "/usr/lib/libc", "/usr/local/bin/x"}
+	if keys := st.Keys(); !slices.Equal(keys, expected) || st.Len() != 4 {
+		t.Errorf("expected %v, but got %v of length %d", expected, keys, st.Len())
+	}
+	if st.Get("/opt/go/bin/gofmt") != 14 || len(st.KeysWithPrefix("/usr/bin")) != 0 {
+		t.Errorf("expected values to move with the keys")
+	}
+	if e := <-events; e.Key != "/opt/go/bin/go" || e.Type != trie.EventPut || e.New != 11 {
+		t.Errorf("expected put event of '/opt/go/bin/go', but got %+v", e)
+	}
+	if !snapshot.Contains("/usr/bin/go") || snapshot.Contains("/opt/go/bin/go") {
+		t.Errorf("expected snapshot to be unchanged, but got %v", snapshot.Keys())
+	}
+	td := []struct {
+		from, to string
+		err      error
+	}{
+		{"/usr/", "/opt/go/", trie.ErrDestinationNotEmpty},
+		{"/usr/", "/usr/local/", trie.ErrPrefixOverlap},
+		{"/usr/local/", "/usr/", trie.ErrPrefixOverlap},
+		{"/usr/", "/usr/", nil},
+		{"/nothing/", "/usr/", nil},
+	}
+	for _, test := range td {
+		if err := st.MovePrefix(test.from, test.to); !errors.Is(err, test.err) {
+			t.Errorf("expected %v for moving '%v' to '%v', but got %v", test.err, test.from, test.to, err)
+		}
+	}
+	if keys := st.Keys(); !slices.Equal(keys, expected) {
+		t.Errorf("expected %v, but got %v", expected, keys)
+	}
+}
+
+func TestSymbolTableMovePrefixNormalized(t *testing.T) {
+	st := trie.NewSymbolTable(trie.WithNormalizer(trie.FoldCase))
+	st.Put("Docs/ReadMe", 1)
+	st.Put("Docs", 2)
+	if err := st.MovePrefix("docs", "Notes"); err != nil {
+		t.Fatal(err)
+	}
+	if keys := st.Keys(); !slices.Equal(keys, []string{"Notes", "Notes/ReadMe"}) {
+		t.Errorf("expected [Notes Notes/ReadMe], but got %v", keys)
+	}
+	if st.Get("notes/readme") != 1 {
+		t.Errorf("expected value 1, but got %v", st.Get("notes/readme"))
+	}
+}
@@ -0,0 +1,144 @@
+package trie // import "kkn.fi/trie"
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrVersionNotFound is returned when a version of a VersionedSymbolTable
+// has not been committed yet or it has been garbage collected.
+var ErrVersionNotFound = errors.New("trie: version not found")
+
+type (
+	tableVersion struct {
+		version uint64
+		table   *SymbolTable
+	}
+	// VersionedSymbolTable is a SymbolTable whose committed states can be
+	// read by their versions. The writes are applied to a working copy of
+	// the symbol table, and Commit makes them visible as a new version. Each
+	// version is a snapshot of the working copy, so the versions share the
+	// nodes that were not written to between them.
+	//
+	// The versions can be read from any number of goroutines while another
+	// goroutine writes and commits. The oldest versions are garbage
+	// collected when there are more than the retained number of them.
+	VersionedSymbolTable struct {
+		mu       sync.RWMutex
+		work     *SymbolTable
+		versions []tableVersion // from the oldest to the latest
+		retain   int
+	}
+)
+
+// NewVersionedSymbolTable returns an empty symbol table with the version 0
+// committed. At most retain latest versions are kept, and a retain of zero or
+// less means that all of the versions are kept.
+func NewVersionedSymbolTable(retain int, opts ...Option) *VersionedSymbolTable {
+	t := &VersionedSymbolTable{
+		work:   NewSymbolTable(opts...),
+		retain: retain,
+	}
+	t.versions = []tableVersion{{table: t.work.Snapshot()}}
+	return t
+}
+
+// Put puts the key-value pair to the working copy. A nil value deletes the
+// key. If key is empty this function will silently return.
+// It returns an *AlphabetError if key has characters outside the alphabet.
+func (t *VersionedSymbolTable) Put(key string, value interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.work.Put(key, value)
+}
+
+// Delete deletes key from the working copy if it is present.
+func (t *VersionedSymbolTable) Delete(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.work.Delete(key)
+}
+
+// Commit makes the writes to the working copy visible as a new version and
+// returns the version. The versions increase monotonically.
+func (t *VersionedSymbolTable) Commit() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	v := tableVersion{
+		version: t.versions[len(t.versions)-1].version + 1,
+		table:   t.work.Snapshot(),
+	}
+	t.versions = append(t.versions, v)
+	if t.retain > 0 && len(t.versions) > t.retain {
+		n := len(t.versions) - t.retain
+		clear(t.versions[:n])
+		t.versions = t.versions[n:]
+	}
+	return v.version
+}
+
+// Version returns the latest committed version.
+func (t *VersionedSymbolTable) Version() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.versions[len(t.versions)-1].version
+}
+
+// Oldest returns the oldest version that has not been garbage collected.
+func (t *VersionedSymbolTable) Oldest() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.versions[0].version
+}
+
+// returns the symbol table of the latest version
+func (t *VersionedSymbolTable) latest() *SymbolTable {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.versions[len(t.versions)-1].table
+}
+
+// At returns the symbol table of version. The symbol table must only be
+// read from. It returns ErrVersionNotFound if the version has not been
+// committed or it has been garbage collected.
+func (t *VersionedSymbolTable) At(version uint64) (*SymbolTable, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	i := sort.Search(len(t.versions), func(i int) bool {
+		return t.versions[i].version >= version
+	})
+	if i == len(t.versions) || t.versions[i].version != version {
+		return nil, ErrVersionNotFound
+	}
+	return t.versions[i].table, nil
+}
+
+// Get returns the value associated with key in the latest version.
+func (t *VersionedSymbolTable) Get(key string) interface{} {
+	return t.latest().Get(key)
+}
+
+// GetAt returns the value associated with key in version.
+func (t *VersionedSymbolTable) GetAt(key string, version uint64) (interface{}, error) {
+	st, err := t.At(version)
+	if err != nil {
+		return nil, err
+	}
+	return st.Get(key), nil
+}
+
+// KeysWithPrefix returns the keys starting with prefix in the latest
+// version.
+func (t *VersionedSymbolTable) KeysWithPrefix(prefix string) []string {
+	return t.latest().KeysWithPrefix(prefix)
+}
+
+// KeysWithPrefixAt returns the keys starting with prefix in version.
+func (t *VersionedSymbolTable) KeysWithPrefixAt(prefix string, version uint64) ([]string, error) {
+	st, err := t.At(version)
+	if err != nil {
+		return nil, err
+	}
+	return st.KeysWithPrefix(prefix), nil
+}
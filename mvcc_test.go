@@ -0,0 +1,72 @@
+package trie_test
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+func TestVersionedSymbolTable(t *testing.T) {
+	st := trie.NewVersionedSymbolTable(3)
+	st.Put("she", 1)
+	st.Put("sells", 2)
+	v1 := st.Commit()
+	st.Put("she", 3)
+	st.Delete("sells")
+	st.Put("shells", 4)
+	if st.Get("she") != 1 {
+		t.Errorf("expected uncommitted writes to be invisible, but got %v", st.Get("she"))
+	}
+	v2 := st.Commit()
+	if v1 != 1 || v2 != 2 || st.Version() != 2 {
+		t.Errorf("expected versions 1 and 2, but got %d and %d", v1, v2)
+	}
+	if value, _ := st.GetAt("she", v1); value != 1 {
+		t.Errorf("expected value 1 at version 1, but got %v", value)
+	}
+	if keys, _ := st.KeysWithPrefixAt("s", v1); !slices.Equal(keys, []string{"sells", "she"}) {
+		t.Errorf("expected [sells she] at version 1, but got %v", keys)
+	}
+	if keys := st.KeysWithPrefix("s"); !slices.Equal(keys, []string{"she", "shells"}) {
+		t.Errorf("expected [she shells], but got %v", keys)
+	}
+	if _, err := st.GetAt("she", 3); !errors.Is(err, trie.ErrVersionNotFound) {
+		t.Errorf("expected %v, but got %v", trie.ErrVersionNotFound, err)
+	}
+	st.Commit()
+	st.Commit()
+	if _, err := st.At(v1); !errors.Is(err, trie.ErrVersionNotFound) || st.Oldest() != v2 {
+		t.Errorf("expected version 1 to be garbage collected, but got %v and oldest %d", err, st.Oldest())
+	}
+	if value, err := st.GetAt("shells", v2); value != 4 || err != nil {
+		t.Errorf("expected value 4 at version 2, but got %v and %v", value, err)
+	}
+}
+
+func TestVersionedSymbolTableConcurrentReads(t *testing.T) {
+	st := trie.NewVersionedSymbolTable(0)
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				v := st.Version()
+				keys, err := st.KeysWithPrefixAt("key", v)
+				if err != nil || len(keys) != int(v) {
+					t.Errorf("expected %d keys at version %d, but got %d and %v", v, v, len(keys), err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 200; i++ {
+		st.Put("key"+strconv.Itoa(i), i)
+		st.Commit()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,119 @@
+package trie // import "kkn.fi/trie"
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Normalizer maps a key to the normalized form that is used for storage and
+// lookup. Keys with equal normalized forms are the same key. Any function of
+// this type can be used, for example norm.NFC.String of
+// golang.org/x/text/unicode/norm.
+type Normalizer func(key string) string
+
+// fullCaseFolds are the case foldings that map one character to several.
+var fullCaseFolds = map[rune]string{
+	'ß': "ss",
+	'ẞ': "ss",
+	'İ': "i̇",
+	'ŉ': "ʼn",
+	'ǰ': "ǰ",
+	'ẖ': "ẖ",
+	'ẗ': "ẗ",
+	'ẘ': "ẘ",
+	'ẙ': "ẙ",
+	'ﬀ': "ff",
+	'ﬁ': "fi",
+	'ﬂ': "fl",
+	'ﬃ': "ffi",
+	'ﬄ': "ffl",
+	'ﬅ': "st",
+	'ﬆ': "st",
+	'և': "եւ",
+}
+
+// decompositions maps the Latin letters that have no canonical decomposition
+// to their base letters.
+var decompositions = map[rune]string{
+	'Æ': "AE", 'æ': "ae", 'Ð': "D", 'ð': "d", 'Ø': "O", 'ø': "o",
+	'Þ': "TH", 'þ': "th", 'Đ': "D", 'đ': "d", 'Ħ': "H", 'ħ': "h",
+	'ı': "i", 'Ĳ': "IJ", 'ĳ': "ij", 'Ŀ': "L", 'ŀ': "l", 'Ł': "L",
+	'ł': "l", 'Œ': "OE", 'œ': "oe", 'Ŧ': "T", 'ŧ': "t", 'ſ': "s",
+}
+
+// baseLetters maps the precomposed letters of the Latin-1 Supplement and
+// Latin Extended-A blocks, from U+00C0 to U+017F, to their base letters.
+// A space marks a character without a base letter.
+const baseLetters = "" +
+	"AAAAAA CEEEEIIII" + // U+00C0
+	" NOOOOO  UUUUY  " + // U+00D0
+	"aaaaaa ceeeeiiii" + // U+00E0
+	" nooooo  uuuuy y" + // U+00F0
+	"AaAaAaCcCcCcCcDd" + // U+0100
+	"  EeEeEeEeEeGgGg" + // U+0110
+	"GgGgHh  IiIiIiIi" + // U+0120
+	"I   JjKk LlLlLl " + // U+0130
+	"   NnNnNn   OoOo" + // U+0140
+	"Oo  RrRrRrSsSsSs" + // U+0150
+	"SsTtTt  UuUuUuUu" + // U+0160
+	"UuUuWwYyYZzZzZz " // U+0170
+
+// FoldCase returns key with Unicode case folding applied, so that keys
+// differing only in case, like "Straße", "STRASSE" and "strasse", have the
+// same normalized form.
+//
+// FoldCase does not compose or decompose characters, so canonically
+// equivalent keys like "É" and "E\u0301" have different normalized forms
+// unless the key is first normalized to NFC, for example with
+// WithNormalizer(norm.NFC.String, FoldCase).
+func FoldCase(key string) string {
+	var b strings.Builder
+	b.Grow(len(key))
+	for _, c := range key {
+		if s, ok := fullCaseFolds[c]; ok {
+			b.WriteString(s)
+			continue
+		}
+		b.WriteRune(unicode.ToLower(unicode.ToUpper(c)))
+	}
+	return b.String()
+}
+
+// StripDiacritics returns key with combining marks removed and the
+// precomposed letters of the Latin-1 Supplement and Latin Extended-A blocks,
+// from U+00C0 to U+017F, replaced with their base letters, so that
+// "Crème Brûlée" becomes "Creme Brulee".
+//
+// StripDiacritics does not decompose other precomposed characters, like the
+// "ệ" of "Việt", "Ǎ" or "Ș", which are retained as is. To strip the
+// diacritics of all of them, decompose the key to NFD first, for example
+// with WithNormalizer(norm.NFD.String, StripDiacritics).
+func StripDiacritics(key string) string {
+	var b strings.Builder
+	b.Grow(len(key))
+	for _, c := range key {
+		switch {
+		case unicode.Is(unicode.Mn, c):
+		case c >= 0xc0 && c <= 0x17f && baseLetters[c-0xc0] != ' ':
+			b.WriteByte(baseLetters[c-0xc0])
+		case decompositions[c] != "":
+			b.WriteString(decompositions[c])
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// chain returns a normalizer that applies normalizers in order.
+func chain(normalizers []Normalizer) Normalizer {
+	if len(normalizers) == 1 {
+		return normalizers[0]
+	}
+	return func(key string) string {
+		for _, n := range normalizers {
+			key = n(key)
+		}
+		return key
+	}
+}
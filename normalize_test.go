@@ -0,0 +1,46 @@
+package trie_test
+
+import (
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+func TestFoldCase(t *testing.T) {
+	td := []struct {
+		key      string
+		expected string
+	}{
+		{"Straße", "strasse"},
+		{"STRASSE", "strasse"},
+		{"strasse", "strasse"},
+		{"ΣΊΣΥΦΟΣ", "σίσυφοσ"},
+		{"ﬁle", "file"},
+	}
+	for _, test := range td {
+		if result := trie.FoldCase(test.key); result != test.expected {
+			t.Errorf("expected '%v', but got '%v'", test.expected, result)
+		}
+	}
+}
+
+func TestStripDiacritics(t *testing.T) {
+	td := []struct {
+		key      string
+		expected string
+	}{
+		{"Crème Brûlée", "Creme Brulee"},
+		{"Crème", "Creme"},
+		{"Łódź", "Lodz"},
+		{"Ærøskøbing", "AEroskobing"},
+		{"日本", "日本"},
+		{"Vie\u0323\u0302t", "Viet"},
+		// precomposed letters outside U+00C0 to U+017F are not decomposed
+		{"Vi\u1ec7t", "Vi\u1ec7t"},
+	}
+	for _, test := range td {
+		if result := trie.StripDiacritics(test.key); result != test.expected {
+			t.Errorf("expected '%v', but got '%v'", test.expected, result)
+		}
+	}
+}
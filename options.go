@@ -0,0 +1,64 @@
+package trie // import "kkn.fi/trie"
+
+import "time"
+
+type (
+	// Option configures a trie when it is constructed.
+	Option func(*config)
+	config struct {
+		alphabet  Alphabet
+		normalize Normalizer
+		clock     func() time.Time
+		codec     Codec
+	}
+)
+
+// WithAlphabet sets the alphabet of the keys of a Trie or a SymbolTable.
+// The default alphabet is ExtendedASCII. The option has no effect on a
+// TernarySearch.
+func WithAlphabet(a Alphabet) Option {
+	return func(c *config) {
+		c.alphabet = a
+	}
+}
+
+// WithNormalizer sets the normalizers that are applied in order to string
+// keys before storage and lookup. The original spelling of a key is
+// remembered and returned by the functions that list keys. Byte slice keys
+// are not normalized.
+func WithNormalizer(n ...Normalizer) Option {
+	return func(c *config) {
+		if len(n) > 0 {
+			c.normalize = chain(n)
+		}
+	}
+}
+
+// WithClock sets the function that returns the current time to an
+// ExpiringSymbolTable. The default clock is time.Now. The option has no effect
+// on the other tries.
+func WithClock(now func() time.Time) Option {
+	return func(c *config) {
+		c.clock = now
+	}
+}
+
+// WithCodec sets the codec of the values of a DurableSymbolTable. The
+// default codec is GobCodec. The option has no effect on the other tries.
+func WithCodec(codec Codec) Option {
+	return func(c *config) {
+		c.codec = codec
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		alphabet: ExtendedASCII,
+		clock:    time.Now,
+		codec:    GobCodec{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
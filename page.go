@@ -0,0 +1,44 @@
+package trie // import "kkn.fi/trie"
+
+import (
+	"cmp"
+	"encoding/base64"
+	"errors"
+)
+
+// ErrInvalidToken is returned when a continuation token of a paginated
+// query is malformed.
+var ErrInvalidToken = errors.New("trie: invalid continuation token")
+
+// encodeToken returns the continuation token of the key with characters
+// chars.
+func encodeToken(chars []rune) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(string(chars)))
+}
+
+// decodeToken returns the characters of the key of a continuation token.
+func decodeToken(token string) ([]rune, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) == 0 {
+		return nil, ErrInvalidToken
+	}
+	return []rune(string(b)), nil
+}
+
+// afterPrefix compares the key after, where a page starts, to the keys that
+// start with prefix. It returns -1 if after is before all of the keys and 1
+// if it is after all of them. Otherwise after starts with prefix and it
+// returns the rest of after.
+func afterPrefix[T cmp.Ordered](prefix, after []T) (rest []T, order int) {
+	d := 0
+	for d < len(prefix) && d < len(after) && prefix[d] == after[d] {
+		d++
+	}
+	switch {
+	case d == len(prefix):
+		return after[d:], 0
+	case d == len(after) || after[d] < prefix[d]:
+		return nil, -1
+	}
+	return nil, 1
+}
@@ -0,0 +1,74 @@
+package trie_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+type pager interface {
+	KeysWithPrefix(prefix string) []string
+	KeysWithPrefixAfter(prefix, token string, limit int) ([]string, string, error)
+}
+
+var pageData = []string{"a", "ab", "abc", "abd", "b", "ba", "bab", "bb", "c", "sea", "sells", "she", "shells", "shore"}
+
+func pagers() map[string]pager {
+	st := trie.New()
+	table := trie.NewSymbolTable()
+	ts := trie.NewTernarySearch()
+	for i, w := range pageData {
+		st.Add(w)
+		table.Put(w, i)
+		ts.Put(w, i)
+	}
+	return map[string]pager{"Trie": st, "SymbolTable": table, "TernarySearch": ts}
+}
+
+func TestKeysWithPrefixAfter(t *testing.T) {
+	for name, p := range pagers() {
+		for _, prefix := range []string{"", "a", "ab", "b", "s", "sh", "x"} {
+			for limit := 1; limit <= 4; limit++ {
+				var keys []string
+				token := ""
+				for {
+					page, next, err := p.KeysWithPrefixAfter(prefix, token, limit)
+					if err != nil {
+						t.Fatalf("%v: unexpected error %v", name, err)
+					}
+					if len(page) > limit {
+						t.Fatalf("%v: expected at most %d keys, but got %v", name, limit, page)
+					}
+					keys = append(keys, page...)
+					if next == "" {
+						break
+					}
+					token = next
+				}
+				if expected := p.KeysWithPrefix(prefix); !slices.Equal(keys, expected) {
+					t.Errorf("%v: expected %v with prefix '%v' and limit %d, but got %v", name, expected, prefix, limit, keys)
+				}
+			}
+		}
+	}
+}
+
+func TestKeysWithPrefixAfterOtherPrefix(t *testing.T) {
+	for name, p := range pagers() {
+		_, token, _ := p.KeysWithPrefixAfter("", "", 2)
+		keys, _, err := p.KeysWithPrefixAfter("b", token, 0)
+		if err != nil || !slices.Equal(keys, []string{"b", "ba", "bab", "bb"}) {
+			t.Errorf("%v: expected [b ba bab bb], but got %v, %v", name, keys, err)
+		}
+		_, token, _ = p.KeysWithPrefixAfter("s", "", 1)
+		keys, _, err = p.KeysWithPrefixAfter("b", token, 0)
+		if err != nil || len(keys) != 0 {
+			t.Errorf("%v: expected no keys, but got %v, %v", name, keys, err)
+		}
+		if _, _, err := p.KeysWithPrefixAfter("", "!", 1); !errors.Is(err, trie.ErrInvalidToken) {
+			t.Errorf("%v: expected invalid token error, but got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package trie // import "kkn.fi/trie"
+
+import "reflect"
+
+// setOp is a set operation of tries.
+type setOp int
+
+const (
+	opUnion setOp = iota
+	opIntersection
+	opDifference
+	opSymmetricDifference
+)
+
+// Union returns a new set of the keys that are in t or in other.
+func (t *Trie) Union(other *Trie) *Trie {
+	return t.combine(other, opUnion)
+}
+
+// Intersection returns a new set of the keys that are in both t and other.
+func (t *Trie) Intersection(other *Trie) *Trie {
+	return t.combine(other, opIntersection)
+}
+
+// Difference returns a new set of the keys that are in t but not in other.
+func (t *Trie) Difference(other *Trie) *Trie {
+	return t.combine(other, opDifference)
+}
+
+// SymmetricDifference returns a new set of the keys that are in either t or
+// other but not in both.
+func (t *Trie) SymmetricDifference(other *Trie) *Trie {
+	return t.combine(other, opSymmetricDifference)
+}
+
+// combine returns the set operation op of t and other. The tries are walked
+// in lockstep, and the subtries that are in the result as such are shared
+// with t and other instead of copying them, except for the nodes that t or
+// other own and may write to in place, which are copied. The nodes of a
+// snapshot are not owned by it, so the operations on snapshots share all of
+// their subtries. The result has the alphabet and the normalizers of t, and
+// the tries should have the same normalizers. The keys are added one by one
+// if the tries have different alphabets, and the alphabet of the result is
+// then extended with the characters of the keys of other that are not in the
+// alphabet of t.
+func (t *Trie) combine(other *Trie, op setOp) *Trie {
+	res := &Trie{
+		alphabet:  t.Alphabet(),
+		normalize: t.normalize,
+		gen:       newGeneration(),
+	}
+	if !sameAlphabet(t.Alphabet(), other.Alphabet()) {
+		var keys []string
+		for key := range t.All() {
+			if in := other.Contains(key); op == opUnion || in == (op == opIntersection) {
+				keys = append(keys, key)
+			}
+		}
+		if op == opUnion || op == opSymmetricDifference {
+			for key := range other.All() {
+				if !t.Contains(key) {
+					keys = append(keys, key)
+				}
+			}
+		}
+		res.alphabet = extendAlphabet(res.Alphabet(), keys, res.normalized)
+		for _, key := range keys {
+			res.Add(key) // cannot fail, the alphabet has every character
+		}
+		return res
+	}
+	owned := func(x *node) bool {
+		return x.gen == t.gen || x.gen == other.gen
+	}
+	res.root = res.combineNodes(t.root, other.root, op, owned)
+	if res.root != nil {
+		res.length = res.root.size
+	}
+	return res
+}
+
+// returns the set operation op of the subtries rooted at x and y
+func (t *Trie) combineNodes(x, y *node, op setOp, owned func(*node) bool) *node {
+	if x == y {
+		if op == opUnion || op == opIntersection {
+			return t.share(x, owned)
+		}
+		return nil
+	}
+	if x == nil || y == nil {
+		switch {
+		case op == opUnion || op == opSymmetricDifference:
+			if x == nil {
+				return t.share(y, owned)
+			}
+			return t.share(x, owned)
+		case op == opDifference:
+			return t.share(x, owned)
+		}
+		return nil
+	}
+	z := t.newNode()
+	switch op {
+	case opUnion:
+		z.isString = x.isString || y.isString
+	case opIntersection:
+		z.isString = x.isString && y.isString
+	case opDifference:
+		z.isString = x.isString && !y.isString
+	case opSymmetricDifference:
+		z.isString = x.isString != y.isString
+	}
+	if z.isString {
+		z.size = 1
+		z.key = x.key
+		if !x.isString {
+			z.key = y.key
+		}
+	}
+	for i := range z.next {
+		if next := t.combineNodes(x.next[i], y.next[i], op, owned); next != nil {
+			z.next[i] = next
+			z.size += next.size
+		}
+	}
+	if z.size == 0 {
+		return nil
+	}
+	return z
+}
+
+// returns the subtrie rooted at x, copying the nodes for which owned returns
+// true, since their owner may write to them in place
+func (t *Trie) share(x *node, owned func(*node) bool) *node {
+	if x == nil || !owned(x) {
+		return x
+	}
+	c := *x
+	c.next = make([]*node, len(x.next))
+	for i, next := range x.next {
+		c.next[i] = t.share(next, owned)
+	}
+	c.gen = t.gen
+	return &c
+}
+
+// Merge puts the key-value pairs of other to the symbol table. If a key is
+// in both symbol tables, its value is the value returned by resolve, which
+// is called with the key and the values of the key in t and in other. A nil
+// value deletes the key.
+//
+// The symbol tables are walked in lockstep, and the subtries of other that
+// are not in t are shared with other instead of copying them, except for the
+// nodes that other owns and may write to in place. The keys of
+// other are put one by one if the symbol tables have different alphabets.
+// It returns an *AlphabetError, and merges nothing, if a key of other has
+// characters outside the alphabet of t.
+func (t *SymbolTable) Merge(other *SymbolTable, resolve func(key string, old, new interface{}) interface{}) error {
+	if t == other {
+		return nil
+	}
+	if !sameAlphabet(t.Alphabet(), other.Alphabet()) {
+		for key := range other.All() {
+			if _, err := toIndices(t.Alphabet(), t.normalized(key)); err != nil {
+				return err
+			}
+		}
+		for key, value := range other.All() {
+			t.Update(key, func(old interface{}, exists bool) (interface{}, bool) {
+				if exists {
+					value = resolve(key, old, value)
+				}
+				return value, true
+			})
+		}
+		return nil
+	}
+	// the nodes of other must not be written to in place by t
+	if t.gen == other.gen {
+		t.gen = newGeneration()
+	}
+	t.root = t.merge(t.root, other.root, other, nil, resolve)
+	t.writes++
+	return nil
+}
+
+// merges the subtrie rooted at y of other to the subtrie rooted at x, where
+// key are the character indices of x and y
+func (t *SymbolTable) merge(x, y *sTNode, other *SymbolTable, key []int, resolve func(key string, old, new interface{}) interface{}) *sTNode {
+	if y == nil || x == y {
+		return x
+	}
+	if x == nil {
+		t.visitIndices(y, key, func(key []int, y *sTNode) {
+			t.length++
+			t.notify(key, y.key, nil, y.value)
+		})
+		return t.share(y, other)
+	}
+	x = t.mutable(x)
+	if y.value != nil {
+		old, value := x.value, y.value
+		spelling := y.key
+		if old != nil {
+			spelling = x.spelling(toChars(t.Alphabet(), key))
+			value = resolve(spelling, old, value)
+		}
+		switch {
+		case old == nil && value != nil:
+			t.length++
+		case old != nil && value == nil:
+			t.length--
+		}
+		if value != nil && t.normalize != nil && old == nil {
+			x.key = spelling
+		} else if value == nil {
+			x.key = ""
+		}
+		x.value = value
+		if !reflect.DeepEqual(old, value) {
+			t.notify(key, spelling, old, value)
+		}
+	}
+	for i, next := range y.next {
+		if next != nil {
+			x.next[i] = t.merge(x.next[i], next, other, append(key, i), resolve)
+		}
+	}
+	return t.prune(x)
+}
+
+// returns the subtrie rooted at x of other, copying the nodes that other
+// owns, since it may write to them in place
+func (t *SymbolTable) share(x *sTNode, other *SymbolTable) *sTNode {
+	if x == nil || x.gen != other.gen {
+		return x
+	}
+	c := &sTNode{
+		next:  make([]*sTNode, len(x.next)),
+		value: x.value,
+		key:   x.key,
+		gen:   t.gen,
+	}
+	c.hash.Store(x.hash.Load())
+	for i, next := range x.next {
+		c.next[i] = t.share(next, other)
+	}
+	return c
+}
+
+// calls visit for each node with a value in the subtrie rooted at x, where
+// key are the character indices of x
+func (t *SymbolTable) visitIndices(x *sTNode, key []int, visit func([]int, *sTNode)) {
+	if x.value != nil {
+		visit(key, x)
+	}
+	for i, next := range x.next {
+		if next != nil {
+			t.visitIndices(next, append(key, i), visit)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package trie_test
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+func TestTrieSetOperations(t *testing.T) {
+	a := trie.New()
+	for _, w := range []string{"she", "sells", "sea", "shells"} {
+		a.Add(w)
+	}
+	b := a.Snapshot()
+	b.Delete("sells")
+	b.Add("shore")
+	b.Add("by")
+	lower := trie.New(trie.WithAlphabet(trie.LowerCase))
+	for _, key := range b.Keys() {
+		lower.Add(key)
+	}
+	td := []struct {
+		name     string
+		op       func(*trie.Trie, *trie.Trie) *trie.Trie
+		expected []string
+	}{
+		{"union", (*trie.Trie).Union, []string{"by", "sea", "sells", "she", "shells", "shore"}},
+		{"intersection", (*trie.Trie).Intersection, []string{"sea", "she", "shells"}},
+		{"difference", (*trie.Trie).Difference, []string{"sells"}},
+		{"symmetric difference", (*trie.Trie).SymmetricDifference, []string{"by", "sells", "shore"}},
+	}
+	for _, test := range td {
+		for _, other := range []*trie.Trie{b, lower} {
+			r := test.op(a, other)
+			if keys := r.Keys(); !slices.Equal(keys, test.expected) || r.Len() != len(test.expected) {
+				t.Errorf("expected %v of length %d for %v, but got %v of length %d", test.expected, len(test.expected), test.name, keys, r.Len())
+			}
+			r.Add("the")
+		}
+	}
+	if a.Len() != 4 || b.Len() != 5 || a.Contains("the") || b.Contains("the") {
+		t.Errorf("expected the operands to be unchanged, but got %v and %v", a.Keys(), b.Keys())
+	}
+	a.Delete("she")
+	if r := a.Union(b); !r.Contains("she") || r.Len() != 6 {
+		t.Errorf("expected union to contain 'she', but got %v", r.Keys())
+	}
+}
+
+func TestTrieSetOperationsLeaveOperands(t *testing.T) {
+	a := trie.New()
+	b := trie.New()
+	for i, w := range data {
+		if i%2 == 0 {
+			a.Add(w)
+		} else {
+			b.Add(w)
+		}
+	}
+	var wg sync.WaitGroup
+	results := make([]*trie.Trie, 4)
+	for i, op := range []func(*trie.Trie, *trie.Trie) *trie.Trie{
+		(*trie.Trie).Union, (*trie.Trie).Intersection, (*trie.Trie).Difference, (*trie.Trie).SymmetricDifference,
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = op(a, b)
+		}()
+	}
+	wg.Wait()
+	union := results[0]
+	keys := union.Keys()
+	// the operands own their nodes, so the result must not share them
+	a.Delete("she")
+	a.DeletePrefix("se")
+	b.Add("shell")
+	b.Delete("by")
+	if !slices.Equal(union.Keys(), keys) || union.Len() != len(keys) {
+		t.Errorf("expected %v, but got %v of length %d", keys, union.Keys(), union.Len())
+	}
+	union.Delete("the")
+	if n := union.DeletePrefix("sh"); n != 3 || union.Len() != 3 {
+		t.Errorf("expected 3 deleted keys and length 3, but got %d and %d", n, union.Len())
+	}
+	if !b.Contains("the") || !b.Contains("shore") || a.Len() != 1 {
+		t.Errorf("expected the operands to be unchanged, but got %v and %v", a.Keys(), b.Keys())
+	}
+}
+
+func TestTrieUnionExtendsAlphabet(t *testing.T) {
+	a := trie.New(trie.WithAlphabet(trie.LowerCase))
+	a.Add("abc")
+	b := trie.New()
+	b.Add("ABC")
+	b.Add("xyz")
+	expected := []string{"abc", "xyz", "ABC"}
+	if keys := a.Union(b).Keys(); !slices.Equal(keys, expected) {
+		t.Errorf("expected %v, but got %v", expected, keys)
+	}
+	if keys := b.SymmetricDifference(a).Keys(); !slices.Equal(keys, []string{"ABC", "abc", "xyz"}) {
+		t.Errorf("expected [ABC abc xyz], but got %v", keys)
+	}
+	if keys := a.SymmetricDifference(b).Keys(); !slices.Equal(keys, []string{"abc", "xyz", "ABC"}) {
+		t.Errorf("expected [abc xyz ABC], but got %v", keys)
+	}
+}
+
+func TestSymbolTableMerge(t *testing.T) {
+	a := trie.NewSymbolTable()
+	b := trie.NewSymbolTable()
+	for i, w := range data {
+		a.Put(w, i)
+		b.Put(w+"s", i)
+	}
+	b.Put("sea", 10)
+	b.Put("the", 11)
+	events, cancel := a.Watch("seas")
+	defer cancel()
+	err := a.Merge(b, func(key string, old, new interface{}) interface{} {
+		if key == "the" {
+			return nil
+		}
+		return old.(int) + new.(int)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"by", "bys", "sea", "seas", "sells", "sellss", "she", "shells", "shellss", "shes", "shore", "shores", "thes"}
+	if keys := a.Keys(); !slices.Equal(keys, expected) || a.Len() != len(expected) {
+		t.Errorf("expected %v, but got %v of length %d", expected, keys, a.Len())
+	}
+	if a.Get("sea") != 16 || a.Get("shells") != 3 || a.Get("seas") != 6 {
+		t.Errorf("expected values 16, 3 and 6, but got %v, %v and %v", a.Get("sea"), a.Get("shells"), a.Get("seas"))
+	}
+	if e := <-events; e.Key != "seas" || e.New != 6 {
+		t.Errorf("expected event of 'seas', but got %+v", e)
+	}
+	a.Put("seas", 20)
+	a.Delete("shes")
+	if b.Get("seas") != 6 || !b.Contains("shes") || b.Len() != 9 {
+		t.Errorf("expected other table to be unchanged, but got %v", b.Keys())
+	}
+}
+
+func TestSymbolTableMergeAlphabetError(t *testing.T) {
+	a := trie.NewSymbolTable(trie.WithAlphabet(trie.LowerCase))
+	a.Put("abc", 1)
+	b := trie.NewSymbolTable()
+	b.Put("xyz", 2)
+	b.Put("ABC", 3)
+	var e *trie.AlphabetError
+	if err := a.Merge(b, nil); !errors.As(err, &e) || e.Key != "ABC" {
+		t.Errorf("expected alphabet error of 'ABC', but got '%v'", err)
+	}
+	if keys := a.Keys(); !slices.Equal(keys, []string{"abc"}) {
+		t.Errorf("expected [abc], but got %v", keys)
+	}
+}
+
+func TestTrieSetOperationsRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	word := func() string {
+		b := make([]byte, 1+rnd.Intn(4))
+		for i := range b {
+			b[i] = "abc"[rnd.Intn(3)]
+		}
+		return string(b)
+	}
+	a, b := trie.New(), trie.New()
+	for i := 0; i < 200; i++ {
+		a.Add(word())
+		b.Add(word())
+		switch rnd.Intn(4) {
+		case 0:
+			a.Delete(word())
+		case 1:
+			b.DeletePrefix(word())
+		case 2:
+			a = a.Snapshot()
+		}
+		for _, r := range []*trie.Trie{a.Union(b), a.Intersection(b), a.Difference(b), a.SymmetricDifference(b)} {
+			if keys := r.Keys(); len(keys) != r.Len() {
+				t.Fatalf("expected length %d of %v, but got %d", len(keys), keys, r.Len())
+			}
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package trie // import "kkn.fi/trie"
+
+import "sort"
+
+type (
+	// Keyboard maps each key of a keyboard to the keys adjacent to it.
+	Keyboard map[rune]string
+	// Suggestion is a correction suggested by a SpellChecker.
+	Suggestion struct {
+		Word      string  // the suggested word
+		Distance  float64 // the edit distance from the misspelled word
+		Frequency int     // the frequency of the word, or zero if unknown
+	}
+	// SpellChecker suggests corrections for misspelled words. The words are
+	// looked up from a Trie and ranked by the edit distance to the misspelled
+	// word and then by their frequency in a SymbolTable.
+	//
+	// The edit distance is the optimal string alignment distance, where an
+	// insertion, a deletion, a substitution and a transposition of two
+	// adjacent characters each cost one. A substitution of a character with
+	// an adjacent key of the keyboard costs AdjacentCost instead. The distance
+	// is computed while walking the trie, and the subtries that cannot be
+	// within MaxDistance are skipped.
+	SpellChecker struct {
+		// Keyboard is used to find the adjacent keys. It is QWERTY by default.
+		Keyboard Keyboard
+		// AdjacentCost is the cost of a substitution with an adjacent key.
+		// It is 0.5 by default.
+		AdjacentCost float64
+		// MaxDistance is the greatest distance of a suggestion. It is 2 by
+		// default.
+		MaxDistance float64
+
+		words       *Trie
+		frequencies *SymbolTable
+	}
+)
+
+// QWERTY is the QWERTY keyboard.
+var QWERTY = NewKeyboard("1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm")
+
+// NewKeyboard returns a keyboard of rows of keys. Each row is assumed to be
+// shifted half a key to the right from the row above it, so a key is
+// adjacent to the keys next to it in its row, to the two keys above it and to
+// the two keys below it.
+func NewKeyboard(rows ...string) Keyboard {
+	k := make(Keyboard)
+	grid := make([][]rune, len(rows))
+	for i, row := range rows {
+		grid[i] = []rune(row)
+	}
+	at := func(row, col int) (rune, bool) {
+		if row < 0 || row >= len(grid) || col < 0 || col >= len(grid[row]) {
+			return 0, false
+		}
+		return grid[row][col], true
+	}
+	for row := range grid {
+		for col, c := range grid[row] {
+			var adjacent []rune
+			for _, p := range [][2]int{{0, -1}, {0, 1}, {-1, 0}, {-1, 1}, {1, -1}, {1, 0}} {
+				if a, ok := at(row+p[0], col+p[1]); ok {
+					adjacent = append(adjacent, a)
+				}
+			}
+			k[c] = string(adjacent)
+		}
+	}
+	return k
+}
+
+// Adjacent returns true if the keys a and b are adjacent.
+func (k Keyboard) Adjacent(a, b rune) bool {
+	for _, c := range k[a] {
+		if c == b {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSpellChecker returns a spell checker of the words in words. The
+// frequencies of the words are looked up from frequencies, where the values
+// are integers. Frequencies can be nil.
+func NewSpellChecker(words *Trie, frequencies *SymbolTable) *SpellChecker {
+	return &SpellChecker{
+		Keyboard:     QWERTY,
+		AdjacentCost: 0.5,
+		MaxDistance:  2,
+		words:        words,
+		frequencies:  frequencies,
+	}
+}
+
+// Suggest returns at most n suggestions for word, ranked by their distance
+// to word, then by their frequency and then alphabetically. A word in the
+// dictionary is suggested with distance zero. A limit of zero or less means
+// no limit.
+func (s *SpellChecker) Suggest(word string, n int) []Suggestion {
+	var suggestions []Suggestion
+	query := []rune(s.words.normalized(word))
+	row := make([]float64, len(query)+1)
+	for j := range row {
+		row[j] = float64(j)
+	}
+	s.search(s.words.root, nil, query, nil, row, &suggestions)
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		if a.Frequency != b.Frequency {
+			return a.Frequency > b.Frequency
+		}
+		return a.Word < b.Word
+	})
+	if n > 0 && len(suggestions) > n {
+		suggestions = suggestions[:n]
+	}
+	return suggestions
+}
+
+// search finds the suggestions in the subtrie rooted at x, where prefix are
+// the characters of x, and prev and row are the distances from prefix
+// without its last character and from prefix to the prefixes of query
+func (s *SpellChecker) search(x *node, prefix, query []rune, prev, row []float64, suggestions *[]Suggestion) {
+	if x == nil {
+		return
+	}
+	if x.isString && row[len(query)] <= s.MaxDistance {
+		word := x.spelling(prefix)
+		*suggestions = append(*suggestions, Suggestion{
+			Word:      word,
+			Distance:  row[len(query)],
+			Frequency: s.frequency(word),
+		})
+	}
+	a := s.words.Alphabet()
+	for i, next := range x.next {
+		if next == nil {
+			continue
+		}
+		c := a.ToChar(i)
+		nextRow := make([]float64, len(query)+1)
+		nextRow[0] = row[0] + 1
+		best := nextRow[0]
+		for j := 1; j <= len(query); j++ {
+			cost := s.substitutionCost(c, query[j-1])
+			d := min(row[j]+1, nextRow[j-1]+1, row[j-1]+cost)
+			if prev != nil && j > 1 && c == query[j-2] && prefix[len(prefix)-1] == query[j-1] {
+				d = min(d, prev[j-2]+1)
+			}
+			nextRow[j] = d
+			best = min(best, d)
+		}
+		if best <= s.MaxDistance {
+			s.search(next, append(prefix, c), query, row, nextRow, suggestions)
+		}
+	}
+}
+
+func (s *SpellChecker) substitutionCost(a, b rune) float64 {
+	switch {
+	case a == b:
+		return 0
+	case s.Keyboard.Adjacent(a, b):
+		return s.AdjacentCost
+	}
+	return 1
+}
+
+// returns the frequency of word, or zero if it is unknown
+func (s *SpellChecker) frequency(word string) int {
+	if s.frequencies == nil {
+		return 0
+	}
+	switch f := s.frequencies.Get(word).(type) {
+	case int:
+		return f
+	case int32:
+		return int(f)
+	case int64:
+		return int(f)
+	case uint:
+		return int(f)
+	case uint32:
+		return int(f)
+	case uint64:
+		return int(f)
+	}
+	return 0
+}
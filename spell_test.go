@@ -0,0 +1,82 @@
+package trie_test
+
+import (
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+func newSpellChecker() *trie.SpellChecker {
+	words := trie.New()
+	frequencies := trie.NewSymbolTable()
+	for w, f := range map[string]int{
+		"the": 1000, "they": 300, "then": 250, "than": 200, "hello": 50,
+		"help": 80, "held": 20, "world": 40, "word": 60, "sea": 30,
+	} {
+		words.Add(w)
+		frequencies.Put(w, f)
+	}
+	return trie.NewSpellChecker(words, frequencies)
+}
+
+func TestSpellCheckerSuggest(t *testing.T) {
+	sc := newSpellChecker()
+	td := []struct {
+		word     string
+		expected string
+	}{
+		{"teh", "the"},    // transposition
+		{"hllo", "hello"}, // insertion
+		{"helo", "help"},  // adjacent key
+		{"wrold", "world"},
+		{"thw", "the"}, // adjacent key
+		{"hekp", "help"},
+		{"the", "the"},
+	}
+	for _, test := range td {
+		suggestions := sc.Suggest(test.word, 3)
+		if len(suggestions) == 0 || suggestions[0].Word != test.expected {
+			t.Errorf("expected '%v' for '%v', but got %v", test.expected, test.word, suggestions)
+		}
+	}
+	if suggestions := sc.Suggest("xxxxxxx", 0); len(suggestions) != 0 {
+		t.Errorf("expected no suggestions, but got %v", suggestions)
+	}
+}
+
+func TestSpellCheckerRanking(t *testing.T) {
+	sc := newSpellChecker()
+	suggestions := sc.Suggest("thn", 0)
+	if len(suggestions) < 3 {
+		t.Fatalf("expected at least 3 suggestions, but got %v", suggestions)
+	}
+	for i := 1; i < len(suggestions); i++ {
+		a, b := suggestions[i-1], suggestions[i]
+		if a.Distance > b.Distance || a.Distance == b.Distance && a.Frequency < b.Frequency {
+			t.Errorf("expected %v to be ranked after %v", a, b)
+		}
+	}
+	if suggestions[0].Word != "the" || suggestions[0].Distance != 1 {
+		t.Errorf("expected 'the' at distance 1, but got %v", suggestions[0])
+	}
+}
+
+func TestKeyboardAdjacent(t *testing.T) {
+	td := []struct {
+		a, b     rune
+		adjacent bool
+	}{
+		{'g', 'h', true},
+		{'g', 't', true},
+		{'g', 'y', true},
+		{'g', 'b', true},
+		{'g', 'v', true},
+		{'g', 'p', false},
+		{'q', 'a', true},
+	}
+	for _, test := range td {
+		if trie.QWERTY.Adjacent(test.a, test.b) != test.adjacent {
+			t.Errorf("expected adjacency of %q and %q to be %v", test.a, test.b, test.adjacent)
+		}
+	}
+}
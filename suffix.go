@@ -0,0 +1,204 @@
+package trie // import "kkn.fi/trie"
+
+import "sort"
+
+type (
+	sfxNode struct {
+		c     rune
+		left  *sfxNode
+		mid   *sfxNode
+		right *sfxNode
+		keys  map[string]int // number of suffixes of each key through node
+	}
+	// SuffixTrie is a set of strings that supports finding the strings that
+	// contain a given substring. It is a generalized suffix trie: every
+	// suffix of every key is stored in a ternary search trie, and each node
+	// knows the keys whose suffixes pass through it. A substring of a key is
+	// a prefix of one of its suffixes, so the keys containing a substring
+	// are known at the node of the substring.
+	//
+	// The Add and Delete functions take time proportional to the square of
+	// the length of the key. KeysContaining takes time proportional to the
+	// length of the substring and the number of keys found. The trie takes
+	// space proportional to the sum of the squares of the lengths of the
+	// keys, so it suits short keys, like product names.
+	//
+	// The keys can be normalized with WithNormalizer, for example to make
+	// the search case-insensitive. The functions that list keys return the
+	// original spelling of each key.
+	SuffixTrie struct {
+		root      *sfxNode
+		spellings map[string]string // normalized key to original spelling
+		normalize Normalizer
+	}
+)
+
+// NewSuffixTrie returns an empty suffix trie.
+func NewSuffixTrie(opts ...Option) *SuffixTrie {
+	c := newConfig(opts)
+	return &SuffixTrie{
+		spellings: make(map[string]string),
+		normalize: c.normalize,
+	}
+}
+
+// returns key in the normalized form used for storage and lookup
+func (t *SuffixTrie) normalized(key string) string {
+	if t.normalize == nil {
+		return key
+	}
+	return t.normalize(key)
+}
+
+// Add adds a key to the set if not present.
+// If key is empty function will silently return.
+func (t *SuffixTrie) Add(key string) {
+	norm := t.normalized(key)
+	if norm == "" {
+		return
+	}
+	if _, ok := t.spellings[norm]; ok {
+		return
+	}
+	t.spellings[norm] = key
+	runes := []rune(norm)
+	for i := range runes {
+		t.root = t.add(t.root, runes[i:], 0, norm)
+	}
+}
+
+func (t *SuffixTrie) add(x *sfxNode, suffix []rune, d int, key string) *sfxNode {
+	c := suffix[d]
+	if x == nil {
+		x = &sfxNode{
+			c:    c,
+			keys: make(map[string]int),
+		}
+	}
+	if c < x.c {
+		x.left = t.add(x.left, suffix, d, key)
+	} else if c > x.c {
+		x.right = t.add(x.right, suffix, d, key)
+	} else {
+		x.keys[key]++
+		if d < len(suffix)-1 {
+			x.mid = t.add(x.mid, suffix, d+1, key)
+		}
+	}
+	return x
+}
+
+// Delete deletes the key from the set if it is present.
+func (t *SuffixTrie) Delete(key string) {
+	norm := t.normalized(key)
+	if _, ok := t.spellings[norm]; !ok {
+		return
+	}
+	delete(t.spellings, norm)
+	runes := []rune(norm)
+	for i := range runes {
+		t.root = t.delete(t.root, runes[i:], 0, norm)
+	}
+}
+
+func (t *SuffixTrie) delete(x *sfxNode, suffix []rune, d int, key string) *sfxNode {
+	if x == nil {
+		return nil
+	}
+	c := suffix[d]
+	if c < x.c {
+		x.left = t.delete(x.left, suffix, d, key)
+	} else if c > x.c {
+		x.right = t.delete(x.right, suffix, d, key)
+	} else {
+		if x.keys[key]--; x.keys[key] == 0 {
+			delete(x.keys, key)
+		}
+		if d < len(suffix)-1 {
+			x.mid = t.delete(x.mid, suffix, d+1, key)
+		}
+		// no suffix passes through x or the nodes below it
+		if len(x.keys) == 0 {
+			return unlink(x)
+		}
+	}
+	return x
+}
+
+// unlink returns the binary search tree of the siblings of x without x.
+func unlink(x *sfxNode) *sfxNode {
+	if x.left == nil {
+		return x.right
+	}
+	if x.right == nil {
+		return x.left
+	}
+	if x.left.right == nil {
+		x.left.right = x.right
+		return x.left
+	}
+	// replace x with the greatest node of its left subtree
+	p := x.left
+	for p.right.right != nil {
+		p = p.right
+	}
+	m := p.right
+	p.right = m.left
+	m.left, m.right = x.left, x.right
+	return m
+}
+
+// Contains returns true if the set contains key and false otherwise.
+func (t *SuffixTrie) Contains(key string) bool {
+	_, ok := t.spellings[t.normalized(key)]
+	return ok
+}
+
+// Len returns the number of strings in the set.
+func (t *SuffixTrie) Len() int {
+	return len(t.spellings)
+}
+
+// IsEmpty returns true if set is empty.
+func (t *SuffixTrie) IsEmpty() bool {
+	return len(t.spellings) == 0
+}
+
+// Keys returns all the keys in the set in sorted order.
+func (t *SuffixTrie) Keys() []string {
+	return t.KeysContaining("")
+}
+
+// KeysContaining returns the keys in the set that contain substr in sorted
+// order.
+func (t *SuffixTrie) KeysContaining(substr string) []string {
+	results := new(stringQueue)
+	substr = t.normalized(substr)
+	if substr == "" {
+		for _, key := range t.spellings {
+			results.enqueue(key)
+		}
+	} else if x := t.get(t.root, []rune(substr)); x != nil {
+		for key := range x.keys {
+			results.enqueue(t.spellings[key])
+		}
+	}
+	sort.Strings(*results)
+	return results.slice()
+}
+
+// return the node of the string s
+func (t *SuffixTrie) get(x *sfxNode, s []rune) *sfxNode {
+	for d := 0; x != nil; {
+		if c := s[d]; c < x.c {
+			x = x.left
+		} else if c > x.c {
+			x = x.right
+		} else if d < len(s)-1 {
+			x, d = x.mid, d+1
+		} else {
+			return x
+		}
+	}
+	return nil
+}
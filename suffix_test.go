@@ -0,0 +1,70 @@
+package trie_test
+
+import (
+	"slices"
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+var products = []string{"banana bread", "bandana", "cabana", "anagram", "grandma", "nan"}
+
+func TestSuffixTrieKeysContaining(t *testing.T) {
+	st := trie.NewSuffixTrie()
+	for _, p := range products {
+		st.Add(p)
+	}
+	td := []struct {
+		substr   string
+		expected []string
+	}{
+		{"ana", []string{"anagram", "banana bread", "bandana", "cabana"}},
+		{"nan", []string{"banana bread", "nan"}},
+		{"and", []string{"bandana", "grandma"}},
+		{"bread", []string{"banana bread"}},
+		{"xyz", nil},
+		{"", []string{"anagram", "banana bread", "bandana", "cabana", "grandma", "nan"}},
+	}
+	for _, test := range td {
+		if result := st.KeysContaining(test.substr); !slices.Equal(result, test.expected) {
+			t.Errorf("expected %v for '%v', but got %v", test.expected, test.substr, result)
+		}
+	}
+}
+
+func TestSuffixTrieDelete(t *testing.T) {
+	st := trie.NewSuffixTrie()
+	for _, p := range products {
+		st.Add(p)
+	}
+	st.Add("nan")
+	if st.Len() != len(products) {
+		t.Errorf("expected len %d, but got %d", len(products), st.Len())
+	}
+	for _, p := range products[:4] {
+		st.Delete(p)
+	}
+	st.Delete("null")
+	if st.Len() != 2 || st.Contains("cabana") || !st.Contains("nan") {
+		t.Errorf("expected [grandma nan], but got %v", st.Keys())
+	}
+	if result := st.KeysContaining("an"); !slices.Equal(result, []string{"grandma", "nan"}) {
+		t.Errorf("expected [grandma nan], but got %v", result)
+	}
+	if result := st.KeysContaining("ana"); len(result) != 0 {
+		t.Errorf("expected no keys, but got %v", result)
+	}
+	st.Delete("grandma")
+	st.Delete("nan")
+	if !st.IsEmpty() || len(st.KeysContaining("a")) != 0 {
+		t.Error("expected empty suffix trie")
+	}
+}
+
+func TestSuffixTrieNormalizer(t *testing.T) {
+	st := trie.NewSuffixTrie(trie.WithNormalizer(trie.FoldCase))
+	st.Add("Banana Bread")
+	if result := st.KeysContaining("BREAD"); !slices.Equal(result, []string{"Banana Bread"}) {
+		t.Errorf("expected [Banana Bread], but got %v", result)
+	}
+}
@@ -1,9 +1,17 @@
 package trie // import "kkn.fi/trie"
 
+import (
+	"iter"
+	"sync/atomic"
+)
+
 type (
 	sTNode struct {
 		next  []*sTNode
 		value interface{}
+		key   string                 // original spelling of a normalized key
+		gen   uint64                 // generation of the symbol table that owns the node
+		hash  atomic.Pointer[[]byte] // Merkle hash of the subtrie, or nil if not computed
 	}
 	// SymbolTable represents an symbol table of key-value pairs, with
 	// string keys and interface{} values. It supports the usual Put, Get, Contains,
@@ -17,99 +25,426 @@ type (
 	// the convention that values cannot be nil. Setting the value associated with
 	// a key to nil is equivalent to deleting the key from the symbol table.
 	//
-	// This implementation uses a 256-way trie. The Put, Contains, Delete, and
+	// Keys can also be given as byte slices with the *Bytes functions. Each
+	// byte of such a key is one character, so arbitrary binary keys, like
+	// hashes, can be stored. A string key and a byte slice key are equal when
+	// they consist of the same ASCII characters. Get, Contains and their byte
+	// slice variants do not allocate.
+	//
+	// The keys are strings over an alphabet, by default the extended ASCII
+	// alphabet. Keys with characters outside the alphabet cannot be put and
+	// are never contained in the symbol table.
+	//
+	// The keys can be normalized with WithNormalizer, for example to make the
+	// symbol table case-insensitive. The functions that list keys return the
+	// original spelling of each key as it was last put.
+	//
+	// Snapshot returns a copy of the symbol table in constant time. The copy
+	// shares the nodes with the symbol table, and a write to either one copies
+	// only the nodes on the path of the written key.
+	//
+	// This implementation uses an R-way trie, where R is the radix of the
+	// alphabet (256 by default). The Put, Contains, Delete, and
 	// longest prefix functions take time proportional to the length of the key (in
 	// the worst case). Construction takes constant time. The Len, and IsEmpty
 	// functions take constant time. Construction takes constant time.
 	SymbolTable struct {
-		root   *sTNode
-		length int
+		root      *sTNode
+		length    int
+		alphabet  Alphabet
+		normalize Normalizer
+		gen       uint64    // nodes of other generations are shared
+		watch     *watchers // not shared with the copies
+		writes    uint64    // number of node writes, for detecting conflicts
 	}
 )
 
 // NewSymbolTable returns a trie based on symbol table implementation.
-func NewSymbolTable() *SymbolTable {
-	return &SymbolTable{}
+func NewSymbolTable(opts ...Option) *SymbolTable {
+	c := newConfig(opts)
+	return &SymbolTable{
+		alphabet:  c.alphabet,
+		normalize: c.normalize,
+		watch:     new(watchers),
+	}
+}
+
+// Alphabet returns the alphabet of the keys in the symbol table.
+func (t *SymbolTable) Alphabet() Alphabet {
+	if t.alphabet == nil {
+		return ExtendedASCII
+	}
+	return t.alphabet
 }
 
 // Put inserts the key-value pair into the trie, overwriting the old
 // value with the new value if the key is already in the symbol table.
 // If the value is nil, this effectively deletes the key from the symbol table.
 // If key is empty this function will silently return.
-func (t *SymbolTable) Put(key string, value interface{}) {
-	if key == "" {
-		return
+// It returns an *AlphabetError if key has characters outside the alphabet.
+func (t *SymbolTable) Put(key string, value interface{}) error {
+	norm := t.normalized(key)
+	if norm == "" {
+		return nil
+	}
+	indices, err := toIndices(t.Alphabet(), norm)
+	if err != nil {
+		return err
+	}
+	t.putIndices(indices, value, key)
+	return nil
+}
+
+// PutBytes inserts the key-value pair into the trie like Put does.
+func (t *SymbolTable) PutBytes(key []byte, value interface{}) error {
+	if len(key) == 0 {
+		return nil
+	}
+	indices, err := bytesToIndices(t.Alphabet(), key)
+	if err != nil {
+		return err
+	}
+	t.putIndices(indices, value, "")
+	return nil
+}
+
+func (t *SymbolTable) putIndices(key []int, value interface{}, spelling string) {
+	var old interface{}
+	if t.watched() {
+		old = t.getIndices(key)
 	}
+	defer t.notify(key, spelling, old, value)
 	if value == nil {
-		t.Delete(key)
+		t.root = t.delete(t.root, key, 0)
 	} else {
-		t.root = t.put(t.root, []rune(key), value, 0)
+		t.root = t.put(t.root, key, value, 0, spelling)
 	}
 }
 
-func (t *SymbolTable) put(x *sTNode, key []rune, value interface{}, d int) *sTNode {
+func (t *SymbolTable) newNode() *sTNode {
+	t.writes++
+	return &sTNode{
+		next: make([]*sTNode, t.Alphabet().Radix()),
+		gen:  t.gen,
+	}
+}
+
+// returns x, or a copy of x if x is shared with a snapshot, without its
+// cached hash
+func (t *SymbolTable) mutable(x *sTNode) *sTNode {
+	t.writes++
+	if x.gen == t.gen {
+		x.hash.Store(nil)
+		return x
+	}
+	return &sTNode{
+		next:  append([]*sTNode(nil), x.next...),
+		value: x.value,
+		key:   x.key,
+		gen:   t.gen,
+	}
+}
+
+// puts the key-value pair to the subtrie rooted at x, remembering spelling
+// as the original spelling of key if the symbol table is normalized
+func (t *SymbolTable) put(x *sTNode, key []int, value interface{}, d int, spelling string) *sTNode {
 	if x == nil {
-		x = &sTNode{
-			next: make([]*sTNode, r),
-		}
+		x = t.newNode()
+	} else {
+		x = t.mutable(x)
 	}
 	if d == len(key) {
 		if x.value == nil {
 			t.length++
 		}
 		x.value = value
+		if t.normalize != nil {
+			x.key = spelling
+		}
 		return x
 	}
 	c := key[d]
-	x.next[c] = t.put(x.next[c], key, value, d+1)
+	x.next[c] = t.put(x.next[c], key, value, d+1, spelling)
 	return x
 }
 
 // Get returns the value associated with the given key.
 func (t *SymbolTable) Get(key string) interface{} {
-	x := t.get(t.root, []rune(key), 0)
+	x := t.get(t.root, t.normalized(key))
 	if x == nil {
 		return nil
 	}
 	return x.value
 }
 
-func (t *SymbolTable) get(x *sTNode, key []rune, d int) *sTNode {
+// GetBytes returns the value associated with the given key.
+func (t *SymbolTable) GetBytes(key []byte) interface{} {
+	x := t.getBytes(t.root, key)
 	if x == nil {
 		return nil
 	}
-	if d == len(key) {
-		return x
+	return x.value
+}
+
+// returns key in the normalized form used for storage and lookup
+func (t *SymbolTable) normalized(key string) string {
+	if t.normalize == nil {
+		return key
 	}
-	c := key[d]
-	return t.get(x.next[c], key, d+1)
+	return t.normalize(key)
+}
+
+// returns the node of the subtrie rooted at x corresponding to key
+func (t *SymbolTable) get(x *sTNode, key string) *sTNode {
+	a := t.Alphabet()
+	for _, c := range key {
+		if x == nil {
+			return nil
+		}
+		x = t.next(a, x, c)
+	}
+	return x
+}
+
+func (t *SymbolTable) getBytes(x *sTNode, key []byte) *sTNode {
+	a := t.Alphabet()
+	for _, c := range key {
+		if x == nil {
+			return nil
+		}
+		x = t.next(a, x, rune(c))
+	}
+	return x
+}
+
+// returns the child of x for character c or nil if there is none
+func (t *SymbolTable) next(a Alphabet, x *sTNode, c rune) *sTNode {
+	i, ok := a.ToIndex(c)
+	if !ok {
+		return nil
+	}
+	return x.next[i]
 }
 
 // Delete removes the key from the symbol table if the key is present.
 func (t *SymbolTable) Delete(key string) {
-	t.root = t.delete(t.root, []rune(key), 0)
+	indices, err := toIndices(t.Alphabet(), t.normalized(key))
+	if err != nil {
+		return
+	}
+	t.putIndices(indices, nil, key)
 }
 
-func (t *SymbolTable) delete(x *sTNode, key []rune, d int) *sTNode {
+// DeleteBytes removes the key from the symbol table if the key is present.
+func (t *SymbolTable) DeleteBytes(key []byte) {
+	indices, err := bytesToIndices(t.Alphabet(), key)
+	if err != nil {
+		return
+	}
+	t.putIndices(indices, nil, "")
+}
+
+func (t *SymbolTable) delete(x *sTNode, key []int, d int) *sTNode {
 	if x == nil {
 		return nil
 	}
 	if d == len(key) {
-		if x.value != nil {
-			t.length--
+		if x.value == nil {
+			return x
 		}
+		t.length--
+		x = t.mutable(x)
 		x.value = nil
+		x.key = ""
 	} else {
 		c := key[d]
-		x.next[c] = t.delete(x.next[c], key, d+1)
+		next := t.delete(x.next[c], key, d+1)
+		if x.unchanged(c, next) {
+			return x
+		}
+		x = t.mutable(x)
+		x.next[c] = next
 	}
 
 	// remove subtrie rooted at x if it is completely empty
 	if x.value != nil {
 		return x
 	}
-	for c := 0; c < r; c++ {
-		if x.next[c] != nil {
+	for _, next := range x.next {
+		if next != nil {
+			return x
+		}
+	}
+	return nil
+}
+
+// DeletePrefix deletes the keys that start with prefix from the symbol table
+// and returns the number of keys deleted. The subtrie of prefix is detached
+// in time proportional to the length of prefix, and the keys in it are
+// counted in time proportional to its size.
+func (t *SymbolTable) DeletePrefix(prefix string) int {
+	indices, err := toIndices(t.Alphabet(), t.normalized(prefix))
+	if err != nil {
+		return 0
+	}
+	sub := t.nodeOf(indices)
+	if sub == nil {
+		return 0
+	}
+	t.root = t.detach(t.root, indices, 0)
+	t.writes++
+	n := 0
+	t.visitIndices(sub, indices, func(key []int, x *sTNode) {
+		n++
+		t.notify(key, x.key, x.value, nil)
+	})
+	t.length -= n
+	return n
+}
+
+// detaches the subtrie of prefix from the subtrie rooted at x
+func (t *SymbolTable) detach(x *sTNode, prefix []int, d int) *sTNode {
+	if x == nil || d == len(prefix) {
+		return nil
+	}
+	c := prefix[d]
+	next := t.detach(x.next[c], prefix, d+1)
+	if x.unchanged(c, next) {
+		return x
+	}
+	x = t.mutable(x)
+	x.next[c] = next
+	return t.prune(x)
+}
+
+// Update sets the value associated with key to the value returned by fn in a
+// single walk down the trie. The function fn is called with the old value and
+// whether the key is present. If fn returns false, or a nil value, the key is
+// deleted. If key is empty this function will silently return.
+// It returns an *AlphabetError if key has characters outside the alphabet.
+//
+// Update does no locking. The single walk saves the second lookup of a Get
+// followed by a Put, but the read and the write are atomic only with respect
+// to the goroutine that calls Update. Goroutines that share a symbol table
+// must synchronize their calls of Update like any other writes, for example
+// with a sync.Mutex.
+func (t *SymbolTable) Update(key string, fn func(old interface{}, exists bool) (value interface{}, keep bool)) error {
+	return t.update(key, func(old interface{}) (interface{}, bool) {
+		value, keep := fn(old, old != nil)
+		if !keep {
+			value = nil
+		}
+		return value, true
+	})
+}
+
+// GetOrPut returns the value associated with key if it is present.
+// Otherwise it puts value and returns it. The loaded result is true if the
+// value was present. If key is empty this function will silently return.
+// It returns an *AlphabetError if key has characters outside the alphabet.
+// Like Update, GetOrPut is not synchronized, and it counts as a write for
+// synchronization, since it writes when key is not present.
+func (t *SymbolTable) GetOrPut(key string, value interface{}) (actual interface{}, loaded bool, err error) {
+	err = t.update(key, func(old interface{}) (interface{}, bool) {
+		if old != nil {
+			actual, loaded = old, true
+			return old, false
+		}
+		actual = value
+		return value, true
+	})
+	return actual, loaded, err
+}
+
+// Swap puts value and returns the previous value associated with key, if
+// any. The loaded result is true if the key was present. A nil value deletes
+// the key. If key is empty this function will silently return.
+// It returns an *AlphabetError if key has characters outside the alphabet.
+// Like Update, Swap is not synchronized.
+func (t *SymbolTable) Swap(key string, value interface{}) (old interface{}, loaded bool, err error) {
+	err = t.update(key, func(prev interface{}) (interface{}, bool) {
+		old, loaded = prev, prev != nil
+		return value, true
+	})
+	return old, loaded, err
+}
+
+// update sets the value associated with key to the value returned by fn,
+// which is called with the old value, unless fn returns false
+func (t *SymbolTable) update(key string, fn func(old interface{}) (value interface{}, write bool)) error {
+	norm := t.normalized(key)
+	if norm == "" {
+		return nil
+	}
+	indices, err := toIndices(t.Alphabet(), norm)
+	if err != nil {
+		return err
+	}
+	var old, value interface{}
+	var write bool
+	t.root = t.updateNode(t.root, indices, 0, func(prev interface{}) (interface{}, bool) {
+		old = prev
+		value, write = fn(prev)
+		return value, write
+	}, key)
+	if write {
+		t.notify(indices, key, old, value)
+	}
+	return nil
+}
+
+func (t *SymbolTable) updateNode(x *sTNode, key []int, d int, fn func(interface{}) (interface{}, bool), spelling string) *sTNode {
+	if d == len(key) {
+		var old interface{}
+		if x != nil {
+			old = x.value
+		}
+		value, write := fn(old)
+		if !write || value == nil && old == nil {
+			return x
+		}
+		if x == nil {
+			x = t.newNode()
+		} else {
+			x = t.mutable(x)
+		}
+		if old == nil {
+			t.length++
+		}
+		if value == nil {
+			t.length--
+			x.key = ""
+		} else if t.normalize != nil {
+			x.key = spelling
+		}
+		x.value = value
+		return t.prune(x)
+	}
+	c := key[d]
+	var next *sTNode
+	if x != nil {
+		next = x.next[c]
+	}
+	n := t.updateNode(next, key, d+1, fn, spelling)
+	if x == nil && n == nil || x != nil && x.unchanged(c, n) {
+		return x
+	}
+	if x == nil {
+		x = t.newNode()
+	} else {
+		x = t.mutable(x)
+	}
+	x.next[c] = n
+	return t.prune(x)
+}
+
+// returns nil if x has no value and no children, and x otherwise
+func (t *SymbolTable) prune(x *sTNode) *sTNode {
+	if x.value != nil {
+		return x
+	}
+	for _, next := range x.next {
+		if next != nil {
 			return x
 		}
 	}
@@ -121,6 +456,11 @@ func (t *SymbolTable) Contains(key string) bool {
 	return t.Get(key) != nil
 }
 
+// ContainsBytes returns true if the trie contains key and false otherwise.
+func (t *SymbolTable) ContainsBytes(key []byte) bool {
+	return t.GetBytes(key) != nil
+}
+
 // IsEmpty returns true if trie is empty and false otherwise.
 func (t *SymbolTable) IsEmpty() bool {
 	return t.length == 0
@@ -130,29 +470,87 @@ func (t *SymbolTable) IsEmpty() bool {
 // longest prefix of query, or empty string, if no such string is found
 // in the trie.
 func (t *SymbolTable) LongestPrefixOf(query string) string {
-	q := []rune(query)
-	length := t.longestPrefixOf(t.root, q, 0, 0)
-	return string(q[0:length])
+	a := t.Alphabet()
+	query = t.normalized(query)
+	var match *sTNode
+	length, x := 0, t.root
+	for i, c := range query {
+		if x == nil {
+			break
+		}
+		if x.value != nil {
+			length, match = i, x
+		}
+		x = t.next(a, x, c)
+	}
+	if x != nil && x.value != nil {
+		length, match = len(query), x
+	}
+	if match != nil && match.key != "" {
+		return match.key
+	}
+	return query[:length]
 }
 
-func (t *SymbolTable) longestPrefixOf(x *sTNode, query []rune, d, length int) int {
-	if x == nil {
-		return length
+// LongestPrefixOfBytes returns the key in the symbol table that is the
+// longest prefix of query, or an empty slice, if no such key is found in the
+// trie. The returned slice shares its storage with query.
+func (t *SymbolTable) LongestPrefixOfBytes(query []byte) []byte {
+	a := t.Alphabet()
+	length, x := 0, t.root
+	for i, c := range query {
+		if x == nil {
+			return query[:length]
+		}
+		if x.value != nil {
+			length = i
+		}
+		x = t.next(a, x, rune(c))
 	}
-	if x.value != nil {
-		length = d
+	if x != nil && x.value != nil {
+		length = len(query)
 	}
-	if d == len(query) {
-		return length
+	return query[:length]
+}
+
+// PrefixesOf returns all the keys in the symbol table that are prefixes of
+// query, from the shortest to the longest.
+func (t *SymbolTable) PrefixesOf(query string) []string {
+	results := new(stringQueue)
+	for key := range t.AllPrefixesOf(query) {
+		results.enqueue(key)
+	}
+	return results.slice()
+}
+
+// AllPrefixesOf returns an iterator over the key-value pairs in the symbol
+// table whose keys are prefixes of query, from the shortest key to the
+// longest. The pairs are found in one walk down the path of query.
+func (t *SymbolTable) AllPrefixesOf(query string) iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		a := t.Alphabet()
+		query := t.normalized(query)
+		x := t.root
+		for i, c := range query {
+			if x == nil {
+				return
+			}
+			if x.value != nil && !yield(x.spellingOf(query[:i]), x.value) {
+				return
+			}
+			x = t.next(a, x, c)
+		}
+		if x != nil && x.value != nil {
+			yield(x.spellingOf(query), x.value)
+		}
 	}
-	c := query[d]
-	return t.longestPrefixOf(x.next[c], query, d+1, length)
 }
 
 // KeysWithPrefix returns all the keys in the trie that match prefix.
 func (t *SymbolTable) KeysWithPrefix(prefix string) []string {
 	results := new(stringQueue)
-	x := t.get(t.root, []rune(prefix), 0)
+	prefix = t.normalized(prefix)
+	x := t.get(t.root, prefix)
 	t.collect(x, []rune(prefix), results)
 	return results.slice()
 }
@@ -162,20 +560,196 @@ func (t *SymbolTable) collect(x *sTNode, prefix []rune, results *stringQueue) {
 		return
 	}
 	if x.value != nil {
-		results.enqueue(string(prefix))
+		results.enqueue(x.spelling(prefix))
 	}
-	for c := 0; c < r; c++ {
-		prefix = append(prefix, rune(c))
-		t.collect(x.next[c], prefix, results)
+	a := t.Alphabet()
+	for c, next := range x.next {
+		prefix = append(prefix, a.ToChar(c))
+		t.collect(next, prefix, results)
 		prefix = prefix[0 : len(prefix)-1]
 	}
 }
 
+// Walk calls fn for each nonempty prefix of the keys in the symbol table
+// that starts with prefix and its value, in the order of Keys, with each
+// prefix before the longer ones. The value is nil if the prefix is not a key.
+// The action returned by fn tells whether to continue, to skip the prefixes
+// that start with the visited one, or to stop the walk, so a search can be
+// pruned at any prefix, for example to bound its depth.
+func (t *SymbolTable) Walk(prefix string, fn func(key string, value interface{}) WalkAction) {
+	prefix = t.normalized(prefix)
+	x := t.get(t.root, prefix)
+	t.walk(x, []rune(prefix), fn)
+}
+
+// walks the subtrie rooted at x, returning false if the walk was stopped
+func (t *SymbolTable) walk(x *sTNode, prefix []rune, fn func(key string, value interface{}) WalkAction) bool {
+	if x == nil {
+		return true
+	}
+	if len(prefix) > 0 {
+		switch fn(x.spelling(prefix), x.value) {
+		case WalkStop:
+			return false
+		case WalkSkipSubtree:
+			return true
+		}
+	}
+	a := t.Alphabet()
+	for c, next := range x.next {
+		if next == nil {
+			continue
+		}
+		prefix = append(prefix, a.ToChar(c))
+		if !t.walk(next, prefix, fn) {
+			return false
+		}
+		prefix = prefix[0 : len(prefix)-1]
+	}
+	return true
+}
+
+// KeysWithPrefixAfter returns a page of at most limit keys in the symbol
+// table that start with prefix, and a continuation token for the next page.
+// The page starts after the key of token, or with the first key if token is
+// empty. The returned token is empty when there are no more keys. A limit of
+// zero or less means no limit. Resuming from a token takes time proportional
+// to the length of its key instead of the number of keys before it.
+func (t *SymbolTable) KeysWithPrefixAfter(prefix, token string, limit int) ([]string, string, error) {
+	var after []int
+	if token != "" {
+		chars, err := decodeToken(token)
+		if err != nil {
+			return nil, "", err
+		}
+		if after, err = toIndices(t.Alphabet(), string(chars)); err != nil {
+			return nil, "", ErrInvalidToken
+		}
+	}
+	results := new(stringQueue)
+	prefix = t.normalized(prefix)
+	p, err := toIndices(t.Alphabet(), prefix)
+	if err != nil {
+		return results.slice(), "", nil
+	}
+	var last []rune
+	next := ""
+	visit := func(chars []rune, x *sTNode) bool {
+		if limit > 0 && len(*results) == limit {
+			next = encodeToken(last)
+			return false
+		}
+		results.enqueue(x.spelling(chars))
+		last = append(last[:0], chars...)
+		return true
+	}
+	x := t.get(t.root, prefix)
+	rest, order := afterPrefix(p, after)
+	if token == "" || order < 0 {
+		t.visitKeys(x, []rune(prefix), 0, true, visit)
+	} else if order == 0 {
+		t.visitKeysAfter(x, []rune(prefix), rest, visit)
+	}
+	return results.slice(), next, nil
+}
+
+// visits the keys in the subtrie rooted at x, starting from the child at
+// index from, and x itself if self is true, until visit returns false
+func (t *SymbolTable) visitKeys(x *sTNode, chars []rune, from int, self bool, visit func([]rune, *sTNode) bool) bool {
+	if x == nil {
+		return true
+	}
+	if self && x.value != nil && !visit(chars, x) {
+		return false
+	}
+	a := t.Alphabet()
+	for c := from; c < len(x.next); c++ {
+		if x.next[c] == nil {
+			continue
+		}
+		if !t.visitKeys(x.next[c], append(chars, a.ToChar(c)), 0, true, visit) {
+			return false
+		}
+	}
+	return true
+}
+
+// visits the keys in the subtrie rooted at x that are after the key with
+// the character indices after, until visit returns false
+func (t *SymbolTable) visitKeysAfter(x *sTNode, chars []rune, after []int, visit func([]rune, *sTNode) bool) bool {
+	if x == nil {
+		return true
+	}
+	if len(after) == 0 {
+		return t.visitKeys(x, chars, 0, false, visit)
+	}
+	c := after[0]
+	if !t.visitKeysAfter(x.next[c], append(chars, t.Alphabet().ToChar(c)), after[1:], visit) {
+		return false
+	}
+	return t.visitKeys(x, chars, c+1, false, visit)
+}
+
+// All returns an iterator over the key-value pairs in the symbol table in
+// the order of Keys.
+func (t *SymbolTable) All() iter.Seq2[string, interface{}] {
+	return t.WithPrefix("")
+}
+
+// WithPrefix returns an iterator over the key-value pairs in the symbol
+// table whose keys start with prefix, in the order of Keys.
+func (t *SymbolTable) WithPrefix(prefix string) iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		prefix := t.normalized(prefix)
+		x := t.get(t.root, prefix)
+		t.visitKeys(x, []rune(prefix), 0, true, func(chars []rune, x *sTNode) bool {
+			return yield(x.spelling(chars), x.value)
+		})
+	}
+}
+
+// Backward returns an iterator over the key-value pairs in the symbol table
+// in the reverse order of Keys.
+func (t *SymbolTable) Backward() iter.Seq2[string, interface{}] {
+	return t.WithPrefixBackward("")
+}
+
+// WithPrefixBackward returns an iterator over the key-value pairs in the
+// symbol table whose keys start with prefix, in the reverse order of Keys.
+// The pairs are not collected before iterating.
+func (t *SymbolTable) WithPrefixBackward(prefix string) iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		prefix := t.normalized(prefix)
+		x := t.get(t.root, prefix)
+		t.visitKeysBackward(x, []rune(prefix), func(chars []rune, x *sTNode) bool {
+			return yield(x.spelling(chars), x.value)
+		})
+	}
+}
+
+// visits the keys in the subtrie rooted at x in reverse order, until visit
+// returns false
+func (t *SymbolTable) visitKeysBackward(x *sTNode, chars []rune, visit func([]rune, *sTNode) bool) bool {
+	if x == nil {
+		return true
+	}
+	a := t.Alphabet()
+	for c := len(x.next) - 1; c >= 0; c-- {
+		if x.next[c] == nil {
+			continue
+		}
+		if !t.visitKeysBackward(x.next[c], append(chars, a.ToChar(c)), visit) {
+			return false
+		}
+	}
+	return x.value == nil || visit(chars, x)
+}
+
 // KeysThatMatch all of the keys in the symbol table that match pattern,
 // where '.' symbol is treated as a wildcard character.
 func (t *SymbolTable) KeysThatMatch(pattern string) []string {
 	results := new(stringQueue)
-	t.collectWildcard(t.root, []rune(""), []rune(pattern), results)
+	t.collectWildcard(t.root, []rune(""), []rune(t.normalized(pattern)), results)
 	return results.slice()
 }
 
@@ -185,21 +759,22 @@ func (t *SymbolTable) collectWildcard(x *sTNode, prefix, pattern []rune, results
 	}
 	d := len(prefix)
 	if d == len(pattern) && x.value != nil {
-		results.enqueue(string(prefix))
+		results.enqueue(x.spelling(prefix))
 	}
 	if d == len(pattern) {
 		return
 	}
+	a := t.Alphabet()
 	c := pattern[d]
 	if c == '.' {
-		for ch := 0; ch < r; ch++ {
-			prefix = append(prefix, rune(ch))
-			t.collectWildcard(x.next[ch], prefix, pattern, results)
+		for ch, next := range x.next {
+			prefix = append(prefix, a.ToChar(ch))
+			t.collectWildcard(next, prefix, pattern, results)
 			prefix = prefix[0 : len(prefix)-1]
 		}
-	} else {
+	} else if next := t.next(a, x, c); next != nil {
 		prefix = append(prefix, c)
-		t.collectWildcard(x.next[c], prefix, pattern, results)
+		t.collectWildcard(next, prefix, pattern, results)
 	}
 }
 
@@ -212,3 +787,70 @@ func (t *SymbolTable) Keys() []string {
 func (t *SymbolTable) Len() int {
 	return t.length
 }
+
+// Clone returns a deep copy of the symbol table. The values are not copied.
+func (t *SymbolTable) Clone() *SymbolTable {
+	c := *t
+	c.gen = newGeneration()
+	c.root = c.clone(t.root)
+	c.watch = new(watchers)
+	return &c
+}
+
+func (t *SymbolTable) clone(x *sTNode) *sTNode {
+	if x == nil {
+		return nil
+	}
+	c := &sTNode{
+		next:  make([]*sTNode, len(x.next)),
+		value: x.value,
+		key:   x.key,
+		gen:   t.gen,
+	}
+	c.hash.Store(x.hash.Load())
+	for i, next := range x.next {
+		c.next[i] = t.clone(next)
+	}
+	return c
+}
+
+// Snapshot returns a copy of the symbol table in constant time. The nodes
+// are shared until they are written to by either the symbol table or the
+// snapshot. A snapshot can be read while the symbol table is written to.
+func (t *SymbolTable) Snapshot() *SymbolTable {
+	s := *t
+	s.gen = newGeneration()
+	s.watch = new(watchers)
+	t.gen = newGeneration()
+	return &s
+}
+
+// returns true if next is the child of x for c and it has not been written
+// to in place since the hash of x was computed
+func (x *sTNode) unchanged(c int, next *sTNode) bool {
+	return next == x.next[c] && (next == nil || next.cachedHash() != nil || x.cachedHash() == nil)
+}
+
+// returns the cached Merkle hash of the subtrie rooted at x, or nil
+func (x *sTNode) cachedHash() []byte {
+	if h := x.hash.Load(); h != nil {
+		return *h
+	}
+	return nil
+}
+
+// returns the original spelling of the key of x, given its characters
+func (x *sTNode) spelling(chars []rune) string {
+	if x.key != "" {
+		return x.key
+	}
+	return string(chars)
+}
+
+// returns the original spelling of the key of x, given the key
+func (x *sTNode) spellingOf(key string) string {
+	if x.key != "" {
+		return x.key
+	}
+	return key
+}
@@ -1,6 +1,7 @@
 package trie_test
 
 import (
+	"slices"
 	"testing"
 
 	"kkn.fi/trie"
@@ -153,3 +154,325 @@ func TestSymbolTableKeysWithPrefix(t *testing.T) {
 		t.Errorf("expected 0, but got %d results", len(result))
 	}
 }
+
+func TestSymbolTableBytes(t *testing.T) {
+	st := trie.NewSymbolTable()
+	for i, w := range data {
+		st.PutBytes([]byte(w), i)
+	}
+	if st.Len() != 7 {
+		t.Errorf("expected len 7, but got %d", st.Len())
+	}
+	if st.Get("shells") != 3 {
+		t.Errorf("expected 3, but got %v", st.Get("shells"))
+	}
+	if st.GetBytes([]byte("sea")) != 6 {
+		t.Errorf("expected 6, but got %v", st.GetBytes([]byte("sea")))
+	}
+	prefix := st.LongestPrefixOfBytes([]byte("shellsort"))
+	if string(prefix) != "shells" {
+		t.Errorf("expected 'shells', but got '%s'", prefix)
+	}
+
+	hash := []byte{0xff, 0x00, 0xe2, 0x82}
+	st.PutBytes(hash, "hash")
+	if !st.ContainsBytes(hash) {
+		t.Errorf("expected binary key %x to be present", hash)
+	}
+	if st.ContainsBytes(hash[:3]) {
+		t.Errorf("expected binary key %x not to be present", hash[:3])
+	}
+	st.DeleteBytes(hash)
+	if st.ContainsBytes(hash) {
+		t.Errorf("expected binary key %x to be deleted", hash)
+	}
+}
+
+func TestSymbolTableGetDoesNotAllocate(t *testing.T) {
+	st := trie.NewSymbolTable()
+	for i, w := range data {
+		st.Put(w, i)
+	}
+	key := []byte("shells")
+	allocs := testing.AllocsPerRun(100, func() {
+		st.Get("shells")
+		st.GetBytes(key)
+		st.LongestPrefixOf("shellsort")
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, but got %v", allocs)
+	}
+}
+
+func TestSymbolTableAlphabet(t *testing.T) {
+	st := trie.NewSymbolTable(trie.WithAlphabet(trie.DNA))
+	for i, w := range []string{"GATTACA", "GATT", "CAT", "ACGT"} {
+		if err := st.Put(w, i); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if st.Get("GATT") != 1 {
+		t.Errorf("expected 1, but got %v", st.Get("GATT"))
+	}
+	if st.Contains("gatt") || st.Contains("€") {
+		t.Error("expected keys outside alphabet not to be contained")
+	}
+	expected := []string{"ACGT", "CAT", "GATT", "GATTACA"}
+	keys := st.Keys()
+	for i, k := range expected {
+		if keys[i] != k {
+			t.Errorf("expected '%v', but got '%v'", k, keys[i])
+		}
+	}
+	if result := st.LongestPrefixOf("GATTACATTAG"); result != "GATTACA" {
+		t.Errorf("expected 'GATTACA', but got '%v'", result)
+	}
+	if results := st.KeysThatMatch(".A.T"); len(results) != 1 || results[0] != "GATT" {
+		t.Errorf("expected [GATT], but got %v", results)
+	}
+	if err := st.Put("GAUC", 5); err == nil {
+		t.Error("expected error for key outside alphabet")
+	}
+	st.Delete("GAUC")
+	if st.Len() != 4 {
+		t.Errorf("expected len 4, but got %d", st.Len())
+	}
+}
+
+func TestSymbolTableNormalizer(t *testing.T) {
+	st := trie.NewSymbolTable(trie.WithNormalizer(trie.FoldCase))
+	st.Put("Straße", 1)
+	for _, key := range []string{"Straße", "STRASSE", "strasse"} {
+		if st.Get(key) != 1 {
+			t.Errorf("expected key '%v' to return 1, but got %v", key, st.Get(key))
+		}
+	}
+	st.Put("STRASSE", 2)
+	if st.Len() != 1 {
+		t.Errorf("expected len 1, but got %d", st.Len())
+	}
+	keys := st.KeysWithPrefix("stra")
+	if len(keys) != 1 || keys[0] != "STRASSE" {
+		t.Errorf("expected [STRASSE], but got %v", keys)
+	}
+	if prefix := st.LongestPrefixOf("strassenbahn"); prefix != "STRASSE" {
+		t.Errorf("expected 'STRASSE', but got '%v'", prefix)
+	}
+	st.Delete("straße")
+	if !st.IsEmpty() {
+		t.Error("expected symbol table to be empty")
+	}
+}
+
+func TestSymbolTablePrefixesOf(t *testing.T) {
+	st := trie.NewSymbolTable()
+	for i, w := range data {
+		st.Put(w, i)
+	}
+	result := st.PrefixesOf("shellsort")
+	if len(result) != 2 || result[0] != "she" || result[1] != "shells" {
+		t.Errorf("expected [she shells], but got %v", result)
+	}
+	values := []interface{}{}
+	for _, v := range st.AllPrefixesOf("shells") {
+		values = append(values, v)
+	}
+	if len(values) != 2 || values[0] != 0 || values[1] != 3 {
+		t.Errorf("expected [0 3], but got %v", values)
+	}
+}
+
+func TestSymbolTableCloneAndSnapshot(t *testing.T) {
+	st := trie.NewSymbolTable()
+	for i, w := range data {
+		st.Put(w, i)
+	}
+	clone := st.Clone()
+	snapshot := st.Snapshot()
+	st.Put("she", "changed")
+	st.Delete("sea")
+	snapshot.Put("shore", "changed")
+	snapshot2 := snapshot.Snapshot()
+	snapshot.Delete("by")
+
+	for _, c := range []*trie.SymbolTable{clone, snapshot, snapshot2} {
+		if c.Get("she") != 0 || c.Get("sea") != 6 {
+			t.Error("expected copy not to see writes to the original")
+		}
+	}
+	if st.Get("shore") != 7 || clone.Get("shore") != 7 {
+		t.Error("expected original not to see writes to the snapshot")
+	}
+	if snapshot2.Get("by") != 4 || snapshot.Contains("by") {
+		t.Error("expected snapshot of snapshot to be independent")
+	}
+	if st.Len() != 6 || snapshot.Len() != 6 || snapshot2.Len() != 7 {
+		t.Errorf("expected lens 6, 6 and 7, but got %d, %d and %d", st.Len(), snapshot.Len(), snapshot2.Len())
+	}
+}
+
+func TestSymbolTableWalk(t *testing.T) {
+	st := trie.NewSymbolTable()
+	for i, w := range data {
+		st.Put(w, i)
+	}
+	var keys []string
+	st.Walk("", func(key string, value interface{}) trie.WalkAction {
+		if len(key) > 3 {
+			return trie.WalkSkipSubtree
+		}
+		if value != nil && value.(int) > 5 {
+			keys = append(keys, key)
+		}
+		return trie.WalkContinue
+	})
+	if len(keys) != 1 || keys[0] != "sea" {
+		t.Errorf("expected [sea], but got %v", keys)
+	}
+	var visited []string
+	st.Walk("sh", func(key string, value interface{}) trie.WalkAction {
+		visited = append(visited, key)
+		return trie.WalkStop
+	})
+	if !slices.Equal(visited, []string{"sh"}) {
+		t.Errorf("expected walk to stop after [sh], but got %v", visited)
+	}
+	// prune at the prefixes that are not keys
+	keys = nil
+	st.Walk("", func(key string, value interface{}) trie.WalkAction {
+		switch {
+		case key == "sh" || key == "sel":
+			return trie.WalkSkipSubtree
+		case value != nil:
+			keys = append(keys, key)
+		}
+		return trie.WalkContinue
+	})
+	if !slices.Equal(keys, []string{"by", "sea", "the"}) {
+		t.Errorf("expected [by sea the], but got %v", keys)
+	}
+}
+
+func TestSymbolTableBackward(t *testing.T) {
+	st := trie.NewSymbolTable()
+	for i, w := range data {
+		st.Put(w, i)
+	}
+	var keys []string
+	for key, value := range st.Backward() {
+		if st.Get(key) != value {
+			t.Errorf("expected key '%v' to have value %v, but got %v", key, st.Get(key), value)
+		}
+		keys = append(keys, key)
+	}
+	expected := st.Keys()
+	slices.Reverse(expected)
+	if !slices.Equal(keys, expected) {
+		t.Errorf("expected %v, but got %v", expected, keys)
+	}
+	keys = keys[:0]
+	for key := range st.WithPrefixBackward("she") {
+		keys = append(keys, key)
+	}
+	if !slices.Equal(keys, []string{"shells", "she"}) {
+		t.Errorf("expected [shells she], but got %v", keys)
+	}
+	keys = keys[:0]
+	for key := range st.WithPrefix("s") {
+		keys = append(keys, key)
+	}
+	if !slices.Equal(keys, st.KeysWithPrefix("s")) {
+		t.Errorf("expected %v, but got %v", st.KeysWithPrefix("s"), keys)
+	}
+}
+
+func TestSymbolTableUpdate(t *testing.T) {
+	st := trie.NewSymbolTable()
+	count := func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			return 1, true
+		}
+		return old.(int) + 1, true
+	}
+	for _, w := range data {
+		if err := st.Update(w, count); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st.Get("sea") != 2 || st.Get("she") != 1 {
+		t.Errorf("expected counts 2 and 1, but got %v and %v", st.Get("sea"), st.Get("she"))
+	}
+	if st.Len() != 7 {
+		t.Errorf("expected length 7, but got %d", st.Len())
+	}
+	snapshot := st.Snapshot()
+	st.Update("sea", func(old interface{}, exists bool) (interface{}, bool) {
+		return nil, false
+	})
+	if st.Contains("sea") || st.Len() != 6 {
+		t.Errorf("expected 'sea' to be deleted, but got %v and length %d", st.Get("sea"), st.Len())
+	}
+	if snapshot.Get("sea") != 2 {
+		t.Errorf("expected snapshot to have count 2, but got %v", snapshot.Get("sea"))
+	}
+	st.Update("missing", func(old interface{}, exists bool) (interface{}, bool) {
+		return nil, false
+	})
+	if st.Len() != 6 {
+		t.Errorf("expected length 6, but got %d", st.Len())
+	}
+	if !slices.Equal(st.KeysWithPrefix("mis"), nil) {
+		t.Errorf("expected no keys, but got %v", st.KeysWithPrefix("mis"))
+	}
+}
+
+func TestSymbolTableGetOrPutAndSwap(t *testing.T) {
+	st := trie.NewSymbolTable()
+	actual, loaded, err := st.GetOrPut("sea", 1)
+	if actual != 1 || loaded || err != nil {
+		t.Errorf("expected (1, false, nil), but got (%v, %v, %v)", actual, loaded, err)
+	}
+	actual, loaded, _ = st.GetOrPut("sea", 2)
+	if actual != 1 || !loaded {
+		t.Errorf("expected (1, true), but got (%v, %v)", actual, loaded)
+	}
+	old, loaded, _ := st.Swap("sea", 3)
+	if old != 1 || !loaded || st.Get("sea") != 3 {
+		t.Errorf("expected (1, true) and new value 3, but got (%v, %v) and %v", old, loaded, st.Get("sea"))
+	}
+	old, loaded, _ = st.Swap("she", 4)
+	if old != nil || loaded || st.Len() != 2 {
+		t.Errorf("expected (<nil>, false) and length 2, but got (%v, %v) and %d", old, loaded, st.Len())
+	}
+	old, loaded, _ = st.Swap("she", nil)
+	if old != 4 || !loaded || st.Contains("she") || st.Len() != 1 {
+		t.Errorf("expected 'she' to be deleted, but got (%v, %v) and length %d", old, loaded, st.Len())
+	}
+	bin := trie.NewSymbolTable(trie.WithAlphabet(trie.Binary))
+	if _, _, err := bin.GetOrPut("012", 1); err == nil {
+		t.Errorf("expected an alphabet error, but got nil")
+	}
+}
+
+func TestSymbolTableDeletePrefix(t *testing.T) {
+	st := trie.NewSymbolTable()
+	for i, w := range data {
+		st.Put(w, i)
+	}
+	events, cancel := st.Watch("")
+	defer cancel()
+	if n := st.DeletePrefix("she"); n != 2 || st.Len() != 5 {
+		t.Errorf("expected 2 deleted keys and length 5, but got %d and %d", n, st.Len())
+	}
+	for _, e := range []trie.Event{{Type: trie.EventDelete, Key: "she", Old: 0}, {Type: trie.EventDelete, Key: "shells", Old: 3}} {
+		if got := <-events; got != e {
+			t.Errorf("expected %+v, but got %+v", e, got)
+		}
+	}
+	if keys := st.KeysWithPrefix("s"); !slices.Equal(keys, []string{"sea", "sells", "shore"}) {
+		t.Errorf("expected [sea sells shore], but got %v", keys)
+	}
+	if n := st.DeletePrefix("shore"); n != 1 || st.Contains("shore") || len(st.KeysWithPrefix("sh")) != 0 {
+		t.Errorf("expected 'shore' to be deleted, but got %d", n)
+	}
+}
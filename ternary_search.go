@@ -1,5 +1,7 @@
 package trie // import "kkn.fi/trie"
 
+import "iter"
+
 type (
 	tSNode struct {
 		c     rune
@@ -7,18 +9,46 @@ type (
 		mid   *tSNode
 		right *tSNode
 		value interface{}
+		key   string // original spelling of a normalized key
+		gen   uint64 // generation of the trie that owns the node
 	}
 	// TernarySearch is a symbol table with string keys and interface{} values.
 	// It implements ternary search trie.
+	//
+	// Keys can also be given as byte slices with the *Bytes functions. Each
+	// byte of such a key is one character, and it is not normalized. A string
+	// key and a byte slice key are equal when they consist of the same ASCII
+	// characters. GetBytes and ContainsBytes do not allocate.
+	//
+	// The keys can be normalized with WithNormalizer, for example to make the
+	// trie case-insensitive. The functions that list keys return the original
+	// spelling of each key as it was last put.
+	//
+	// Snapshot returns a copy of the trie in constant time. The copy shares
+	// the nodes with the trie, and a write to either one copies only the
+	// nodes on the search path of the written key.
 	TernarySearch struct {
-		length int
-		root   *tSNode
+		length    int
+		root      *tSNode
+		normalize Normalizer
+		gen       uint64 // nodes of other generations are shared
 	}
 )
 
 // NewTernarySearch returns an empty ternary search trie.
-func NewTernarySearch() *TernarySearch {
-	return &TernarySearch{}
+func NewTernarySearch(opts ...Option) *TernarySearch {
+	c := newConfig(opts)
+	return &TernarySearch{
+		normalize: c.normalize,
+	}
+}
+
+// returns key in the normalized form used for storage and lookup
+func (t *TernarySearch) normalized(key string) string {
+	if t.normalize == nil {
+		return key
+	}
+	return t.normalize(key)
 }
 
 // Contains return true for an existing key.
@@ -26,9 +56,15 @@ func (t *TernarySearch) Contains(key string) bool {
 	return t.Get(key) != nil
 }
 
+// ContainsBytes returns true for an existing key.
+func (t *TernarySearch) ContainsBytes(key []byte) bool {
+	return t.GetBytes(key) != nil
+}
+
 // Get returns value for a key. Get will return nil for an empty key or when key
 // is not found.
 func (t *TernarySearch) Get(key string) interface{} {
+	key = t.normalized(key)
 	if key == "" {
 		return nil
 	}
@@ -55,47 +91,246 @@ func (t *TernarySearch) get(x *tSNode, key []rune, d int) *tSNode {
 	return x
 }
 
+// GetBytes returns value for a key. GetBytes will return nil for an empty key
+// or when key is not found.
+func (t *TernarySearch) GetBytes(key []byte) interface{} {
+	x := t.getBytes(key)
+	if x == nil {
+		return nil
+	}
+	return x.value
+}
+
+// returns the node of key without converting it to runes
+func (t *TernarySearch) getBytes(key []byte) *tSNode {
+	x := t.root
+	for d := 0; x != nil && d < len(key); {
+		c := rune(key[d])
+		if c < x.c {
+			x = x.left
+		} else if c > x.c {
+			x = x.right
+		} else if d < len(key)-1 {
+			x = x.mid
+			d++
+		} else {
+			return x
+		}
+	}
+	return nil
+}
+
+// returns the characters of a byte slice key
+func bytesToRunes(key []byte) []rune {
+	chars := make([]rune, len(key))
+	for i, b := range key {
+		chars[i] = rune(b)
+	}
+	return chars
+}
+
 // Put inserts string key into trie
+// If the value is nil, the key is deleted from the trie.
 // If key is empty this function will silently return
 func (t *TernarySearch) Put(key string, val interface{}) {
-	if key == "" {
+	if val == nil {
+		t.Delete(key)
+		return
+	}
+	norm := t.normalized(key)
+	if norm == "" {
 		return
 	}
 	if !t.Contains(key) {
 		t.length++
 	}
-	t.root = t.put(t.root, []rune(key), val, 0)
+	t.root = t.put(t.root, []rune(norm), val, 0, key)
+}
+
+// PutBytes inserts the key-value pair into the trie like Put does.
+func (t *TernarySearch) PutBytes(key []byte, val interface{}) {
+	if val == nil {
+		t.DeleteBytes(key)
+		return
+	}
+	if len(key) == 0 {
+		return
+	}
+	if !t.ContainsBytes(key) {
+		t.length++
+	}
+	t.root = t.put(t.root, bytesToRunes(key), val, 0, "")
 }
 
-func (t *TernarySearch) put(x *tSNode, key []rune, val interface{}, d int) *tSNode {
+// puts the key-value pair to the subtrie rooted at x, remembering spelling
+// as the original spelling of key if the trie is normalized
+func (t *TernarySearch) put(x *tSNode, key []rune, val interface{}, d int, spelling string) *tSNode {
 	c := key[d]
 	if x == nil {
 		x = new(tSNode)
 		x.c = c
+		x.gen = t.gen
+	} else {
+		x = t.mutable(x)
 	}
 	if c < x.c {
-		x.left = t.put(x.left, key, val, d)
+		x.left = t.put(x.left, key, val, d, spelling)
 	} else if c > x.c {
-		x.right = t.put(x.right, key, val, d)
+		x.right = t.put(x.right, key, val, d, spelling)
 	} else if d < len(key)-1 {
-		x.mid = t.put(x.mid, key, val, d+1)
+		x.mid = t.put(x.mid, key, val, d+1, spelling)
 	} else {
 		x.value = val
+		if t.normalize != nil {
+			x.key = spelling
+		}
 	}
 	return x
 }
 
 // Delete removes the key from the trie if the key is present.
 func (t *TernarySearch) Delete(key string) {
-	panic("not implemented!")
+	key = t.normalized(key)
+	if key == "" {
+		return
+	}
+	n := 0
+	t.root = t.delete(t.root, []rune(key), 0, false, &n)
+	t.length -= n
+}
+
+// DeleteBytes removes the key from the trie if the key is present.
+func (t *TernarySearch) DeleteBytes(key []byte) {
+	if len(key) == 0 {
+		return
+	}
+	n := 0
+	t.root = t.delete(t.root, bytesToRunes(key), 0, false, &n)
+	t.length -= n
+}
+
+// DeletePrefix deletes the keys that start with prefix from the trie and
+// returns the number of keys deleted. The subtrie of prefix is detached in
+// time proportional to the length of prefix, and the keys in it are counted
+// in time proportional to its size.
+func (t *TernarySearch) DeletePrefix(prefix string) int {
+	p := []rune(t.normalized(prefix))
+	n := 0
+	if len(p) == 0 {
+		n = t.count(t.root)
+		t.root = nil
+	} else {
+		t.root = t.delete(t.root, p, 0, true, &n)
+	}
+	t.length -= n
+	return n
+}
+
+// deletes key, and the keys that start with it if subtrie is true, from the
+// subtrie rooted at x, storing the number of keys deleted to n
+func (t *TernarySearch) delete(x *tSNode, key []rune, d int, subtrie bool, n *int) *tSNode {
+	if x == nil {
+		return nil
+	}
+	c := key[d]
+	switch {
+	case c < x.c:
+		left := t.delete(x.left, key, d, subtrie, n)
+		if left == x.left {
+			return x
+		}
+		x = t.mutable(x)
+		x.left = left
+		return x
+	case c > x.c:
+		right := t.delete(x.right, key, d, subtrie, n)
+		if right == x.right {
+			return x
+		}
+		x = t.mutable(x)
+		x.right = right
+		return x
+	case d < len(key)-1:
+		mid := t.delete(x.mid, key, d+1, subtrie, n)
+		if mid == x.mid {
+			return x
+		}
+		x = t.mutable(x)
+		x.mid = mid
+	case subtrie:
+		*n = t.count(x.mid)
+		if x.value != nil {
+			*n++
+		}
+		if *n == 0 {
+			return x
+		}
+		x = t.mutable(x)
+		x.mid = nil
+		x.value = nil
+		x.key = ""
+	default:
+		if x.value == nil {
+			return x
+		}
+		*n = 1
+		x = t.mutable(x)
+		x.value = nil
+		x.key = ""
+	}
+	// remove x from its siblings if no key goes through it
+	if x.value != nil || x.mid != nil {
+		return x
+	}
+	return t.unlink(x)
+}
+
+// returns the binary search tree of the siblings of x without x
+func (t *TernarySearch) unlink(x *tSNode) *tSNode {
+	if x.left == nil {
+		return x.right
+	}
+	if x.right == nil {
+		return x.left
+	}
+	m, right := t.removeMin(x.right)
+	m = t.mutable(m)
+	m.left, m.right = x.left, right
+	return m
+}
+
+// returns the least node of the binary search tree rooted at x and the tree
+// without it
+func (t *TernarySearch) removeMin(x *tSNode) (min, rest *tSNode) {
+	if x.left == nil {
+		return x, x.right
+	}
+	min, left := t.removeMin(x.left)
+	x = t.mutable(x)
+	x.left = left
+	return min, x
+}
+
+// returns the number of keys in the subtrie rooted at x
+func (t *TernarySearch) count(x *tSNode) int {
+	if x == nil {
+		return 0
+	}
+	n := t.count(x.left) + t.count(x.mid) + t.count(x.right)
+	if x.value != nil {
+		n++
+	}
+	return n
 }
 
 // LongestPrefixOf returns longest prefix of argument prefix in trie
 func (t *TernarySearch) LongestPrefixOf(query string) string {
+	query = t.normalized(query)
 	if len(query) == 0 {
 		return ""
 	}
 	length := 0
+	var match *tSNode
 	x := t.root
 	q := []rune(query)
 	for i := 0; x != nil && i < len(q); {
@@ -107,14 +342,74 @@ func (t *TernarySearch) LongestPrefixOf(query string) string {
 		} else {
 			i++
 			if x.value != nil {
-				length = i
+				length, match = i, x
 			}
 			x = x.mid
 		}
 	}
+	if match != nil && match.key != "" {
+		return match.key
+	}
 	return string(q[0:length])
 }
 
+// LongestPrefixOfBytes returns the key in the trie that is the longest prefix
+// of query, or an empty slice, if no such key is found. The returned slice
+// shares its storage with query.
+func (t *TernarySearch) LongestPrefixOfBytes(query []byte) []byte {
+	length := 0
+	x := t.root
+	for i := 0; x != nil && i < len(query); {
+		c := rune(query[i])
+		if c < x.c {
+			x = x.left
+		} else if c > x.c {
+			x = x.right
+		} else {
+			i++
+			if x.value != nil {
+				length = i
+			}
+			x = x.mid
+		}
+	}
+	return query[:length]
+}
+
+// PrefixesOf returns all the keys in the trie that are prefixes of query,
+// from the shortest to the longest.
+func (t *TernarySearch) PrefixesOf(query string) []string {
+	queue := new(stringQueue)
+	for key := range t.AllPrefixesOf(query) {
+		queue.enqueue(key)
+	}
+	return queue.slice()
+}
+
+// AllPrefixesOf returns an iterator over the key-value pairs in the trie
+// whose keys are prefixes of query, from the shortest key to the longest.
+// The pairs are found in one walk down the path of query.
+func (t *TernarySearch) AllPrefixesOf(query string) iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		q := []rune(t.normalized(query))
+		x := t.root
+		for i := 0; x != nil && i < len(q); {
+			c := q[i]
+			if c < x.c {
+				x = x.left
+			} else if c > x.c {
+				x = x.right
+			} else {
+				i++
+				if x.value != nil && !yield(x.spelling(q[:i]), x.value) {
+					return
+				}
+				x = x.mid
+			}
+		}
+	}
+}
+
 // Keys returns all the keys in the trie.
 func (t *TernarySearch) Keys() []string {
 	queue := new(stringQueue)
@@ -122,15 +417,21 @@ func (t *TernarySearch) Keys() []string {
 	return queue.slice()
 }
 
-// KeysWithPrefix returns all keys starting with given prefix.
+// KeysWithPrefix returns all keys starting with given prefix. Every key
+// starts with the empty prefix, so it returns all keys, like Trie and
+// SymbolTable.
 func (t *TernarySearch) KeysWithPrefix(prefix string) []string {
+	prefix = t.normalized(prefix)
+	if prefix == "" {
+		return t.Keys()
+	}
 	queue := new(stringQueue)
 	x := t.get(t.root, []rune(prefix), 0)
 	if x == nil {
 		return queue.slice()
 	}
 	if x.value != nil {
-		queue.enqueue(prefix)
+		queue.enqueue(x.spelling([]rune(prefix)))
 	}
 	t.collect(x.mid, []rune(prefix), queue)
 	return queue.slice()
@@ -143,16 +444,234 @@ func (t *TernarySearch) collect(x *tSNode, prefix []rune, queue *stringQueue) {
 	}
 	t.collect(x.left, prefix, queue)
 	if x.value != nil {
-		queue.enqueue(string(append(prefix, x.c)))
+		queue.enqueue(x.spelling(append(prefix, x.c)))
 	}
 	t.collect(x.mid, append(prefix, x.c), queue)
 	t.collect(x.right, prefix, queue)
 }
 
+// Walk calls fn for each nonempty prefix of the keys in the trie that starts
+// with prefix and its value, in the order of Keys, with each prefix before
+// the longer ones. The value is nil if the prefix is not a key. The action
+// returned by fn tells whether to continue, to skip the prefixes that start
+// with the visited one, or to stop the walk, so a search can be pruned at any
+// prefix, for example to bound its depth.
+func (t *TernarySearch) Walk(prefix string, fn func(key string, value interface{}) WalkAction) {
+	p := []rune(t.normalized(prefix))
+	if len(p) == 0 {
+		t.walk(t.root, p, fn)
+		return
+	}
+	x := t.get(t.root, p, 0)
+	if x == nil {
+		return
+	}
+	switch fn(x.spelling(p), x.value) {
+	case WalkStop, WalkSkipSubtree:
+		return
+	}
+	t.walk(x.mid, p, fn)
+}
+
+// walks the subtrie rooted at x, returning false if the walk was stopped
+func (t *TernarySearch) walk(x *tSNode, prefix []rune, fn func(key string, value interface{}) WalkAction) bool {
+	if x == nil {
+		return true
+	}
+	if !t.walk(x.left, prefix, fn) {
+		return false
+	}
+	key := append(prefix, x.c)
+	switch fn(x.spelling(key), x.value) {
+	case WalkStop:
+		return false
+	case WalkContinue:
+		if !t.walk(x.mid, key, fn) {
+			return false
+		}
+	}
+	return t.walk(x.right, prefix, fn)
+}
+
+// KeysWithPrefixAfter returns a page of at most limit keys in the trie that
+// start with prefix, and a continuation token for the next page. The page
+// starts after the key of token, or with the first key if token is empty.
+// The returned token is empty when there are no more keys. A limit of zero or
+// less means no limit. Resuming from a token takes time proportional to the
+// length of its key instead of the number of keys before it.
+func (t *TernarySearch) KeysWithPrefixAfter(prefix, token string, limit int) ([]string, string, error) {
+	var after []rune
+	if token != "" {
+		var err error
+		if after, err = decodeToken(token); err != nil {
+			return nil, "", err
+		}
+	}
+	queue := new(stringQueue)
+	var last []rune
+	next := ""
+	visit := func(chars []rune, x *tSNode) bool {
+		if limit > 0 && len(*queue) == limit {
+			next = encodeToken(last)
+			return false
+		}
+		queue.enqueue(x.spelling(chars))
+		last = append(last[:0], chars...)
+		return true
+	}
+	p := []rune(t.normalized(prefix))
+	rest, order := afterPrefix(p, after)
+	if token == "" {
+		order = -1
+	}
+	if order > 0 {
+		return queue.slice(), "", nil
+	}
+	x := t.root
+	if len(p) > 0 {
+		if x = t.get(t.root, p, 0); x == nil {
+			return queue.slice(), "", nil
+		}
+		if order < 0 && x.value != nil && !visit(p, x) {
+			return queue.slice(), next, nil
+		}
+		x = x.mid
+	}
+	if order < 0 || len(rest) == 0 {
+		t.visitKeys(x, p, visit)
+	} else {
+		t.visitKeysAfter(x, p, rest, visit)
+	}
+	return queue.slice(), next, nil
+}
+
+// visits the keys in the subtrie rooted at x, until visit returns false
+func (t *TernarySearch) visitKeys(x *tSNode, chars []rune, visit func([]rune, *tSNode) bool) bool {
+	if x == nil {
+		return true
+	}
+	if !t.visitKeys(x.left, chars, visit) {
+		return false
+	}
+	key := append(chars, x.c)
+	if x.value != nil && !visit(key, x) {
+		return false
+	}
+	if !t.visitKeys(x.mid, key, visit) {
+		return false
+	}
+	return t.visitKeys(x.right, chars, visit)
+}
+
+// visits the keys in the subtrie rooted at x that are after the key with
+// the characters after, until visit returns false
+func (t *TernarySearch) visitKeysAfter(x *tSNode, chars []rune, after []rune, visit func([]rune, *tSNode) bool) bool {
+	if x == nil {
+		return true
+	}
+	c := after[0]
+	if c > x.c {
+		return t.visitKeysAfter(x.right, chars, after, visit)
+	}
+	if c < x.c && !t.visitKeysAfter(x.left, chars, after, visit) {
+		return false
+	}
+	key := append(chars, x.c)
+	if c < x.c {
+		if x.value != nil && !visit(key, x) {
+			return false
+		}
+		if !t.visitKeys(x.mid, key, visit) {
+			return false
+		}
+	} else if len(after) > 1 {
+		if !t.visitKeysAfter(x.mid, key, after[1:], visit) {
+			return false
+		}
+	} else if !t.visitKeys(x.mid, key, visit) {
+		return false
+	}
+	return t.visitKeys(x.right, chars, visit)
+}
+
+// All returns an iterator over the key-value pairs in the trie in the order
+// of Keys.
+func (t *TernarySearch) All() iter.Seq2[string, interface{}] {
+	return t.WithPrefix("")
+}
+
+// WithPrefix returns an iterator over the key-value pairs in the trie whose
+// keys start with prefix, in the order of Keys.
+func (t *TernarySearch) WithPrefix(prefix string) iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		visit := func(chars []rune, x *tSNode) bool {
+			return yield(x.spelling(chars), x.value)
+		}
+		p := []rune(t.normalized(prefix))
+		if len(p) == 0 {
+			t.visitKeys(t.root, p, visit)
+			return
+		}
+		x := t.get(t.root, p, 0)
+		if x == nil || x.value != nil && !visit(p, x) {
+			return
+		}
+		t.visitKeys(x.mid, p, visit)
+	}
+}
+
+// Backward returns an iterator over the key-value pairs in the trie in the
+// reverse order of Keys.
+func (t *TernarySearch) Backward() iter.Seq2[string, interface{}] {
+	return t.WithPrefixBackward("")
+}
+
+// WithPrefixBackward returns an iterator over the key-value pairs in the trie
+// whose keys start with prefix, in the reverse order of Keys. The pairs are
+// not collected before iterating.
+func (t *TernarySearch) WithPrefixBackward(prefix string) iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		visit := func(chars []rune, x *tSNode) bool {
+			return yield(x.spelling(chars), x.value)
+		}
+		p := []rune(t.normalized(prefix))
+		if len(p) == 0 {
+			t.visitKeysBackward(t.root, p, visit)
+			return
+		}
+		x := t.get(t.root, p, 0)
+		if x == nil || !t.visitKeysBackward(x.mid, p, visit) {
+			return
+		}
+		if x.value != nil {
+			visit(p, x)
+		}
+	}
+}
+
+// visits the keys in the subtrie rooted at x in reverse order, until visit
+// returns false
+func (t *TernarySearch) visitKeysBackward(x *tSNode, chars []rune, visit func([]rune, *tSNode) bool) bool {
+	if x == nil {
+		return true
+	}
+	if !t.visitKeysBackward(x.right, chars, visit) {
+		return false
+	}
+	key := append(chars, x.c)
+	if !t.visitKeysBackward(x.mid, key, visit) {
+		return false
+	}
+	if x.value != nil && !visit(key, x) {
+		return false
+	}
+	return t.visitKeysBackward(x.left, chars, visit)
+}
+
 // KeysThatMatch returns all keys matching given wildcard pattern
 func (t *TernarySearch) KeysThatMatch(pattern string) []string {
 	queue := new(stringQueue)
-	t.collectWildcard(t.root, []rune(""), []rune(pattern), 0, queue)
+	t.collectWildcard(t.root, []rune(""), []rune(t.normalized(pattern)), 0, queue)
 	return queue.slice()
 }
 
@@ -166,7 +685,7 @@ func (t *TernarySearch) collectWildcard(x *tSNode, prefix, pattern []rune, i int
 	}
 	if c == '.' || c == x.c {
 		if i == len(pattern)-1 && x.value != nil {
-			q.enqueue(string(append(prefix, x.c)))
+			q.enqueue(x.spelling(append(prefix, x.c)))
 		}
 		if i < len(pattern)-1 {
 			t.collectWildcard(x.mid, append(prefix, x.c), pattern, i+1, q)
@@ -186,3 +705,51 @@ func (t *TernarySearch) Len() int {
 func (t *TernarySearch) IsEmpty() bool {
 	return t.length == 0
 }
+
+// Clone returns a deep copy of the trie. The values are not copied.
+func (t *TernarySearch) Clone() *TernarySearch {
+	c := *t
+	c.gen = newGeneration()
+	c.root = c.clone(t.root)
+	return &c
+}
+
+func (t *TernarySearch) clone(x *tSNode) *tSNode {
+	if x == nil {
+		return nil
+	}
+	c := *x
+	c.left = t.clone(x.left)
+	c.mid = t.clone(x.mid)
+	c.right = t.clone(x.right)
+	c.gen = t.gen
+	return &c
+}
+
+// Snapshot returns a copy of the trie in constant time. The nodes are shared
+// until they are written to by either the trie or the snapshot. A snapshot
+// can be read while the trie is written to.
+func (t *TernarySearch) Snapshot() *TernarySearch {
+	s := *t
+	s.gen = newGeneration()
+	t.gen = newGeneration()
+	return &s
+}
+
+// returns x, or a copy of x if x is shared with a snapshot
+func (t *TernarySearch) mutable(x *tSNode) *tSNode {
+	if x.gen == t.gen {
+		return x
+	}
+	c := *x
+	c.gen = t.gen
+	return &c
+}
+
+// returns the original spelling of the key of x, given its characters
+func (x *tSNode) spelling(chars []rune) string {
+	if x.key != "" {
+		return x.key
+	}
+	return string(chars)
+}
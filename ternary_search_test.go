@@ -1,6 +1,7 @@
 package trie_test
 
 import (
+	"slices"
 	"testing"
 
 	"kkn.fi/trie"
@@ -74,3 +75,251 @@ func TestTernarySearchDisallowsEmptyKey(t *testing.T) {
 		t.Error("expected trie to contain key '' with value <nil>")
 	}
 }
+
+func TestTernarySearchNormalizer(t *testing.T) {
+	ts := trie.NewTernarySearch(trie.WithNormalizer(trie.FoldCase))
+	ts.Put("Straße", 1)
+	ts.Put("strasse", 2)
+	ts.Put("Strand", 3)
+	if ts.Len() != 2 {
+		t.Errorf("expected len 2, but got %d", ts.Len())
+	}
+	if ts.Get("STRASSE") != 2 {
+		t.Errorf("expected 2, but got %v", ts.Get("STRASSE"))
+	}
+	keys := ts.KeysWithPrefix("STRA")
+	if len(keys) != 2 || keys[0] != "Strand" || keys[1] != "strasse" {
+		t.Errorf("expected [Strand strasse], but got %v", keys)
+	}
+	if prefix := ts.LongestPrefixOf("STRANDED"); prefix != "Strand" {
+		t.Errorf("expected 'Strand', but got '%v'", prefix)
+	}
+}
+
+func TestTernarySearchBytes(t *testing.T) {
+	ts := trie.NewTernarySearch()
+	for i, w := range data {
+		ts.PutBytes([]byte(w), i)
+	}
+	if ts.Len() != 7 {
+		t.Errorf("expected len 7, but got %d", ts.Len())
+	}
+	if ts.Get("shells") != 3 {
+		t.Errorf("expected 3, but got %v", ts.Get("shells"))
+	}
+	if ts.GetBytes([]byte("sea")) != 6 {
+		t.Errorf("expected 6, but got %v", ts.GetBytes([]byte("sea")))
+	}
+	prefix := ts.LongestPrefixOfBytes([]byte("shellsort"))
+	if string(prefix) != "shells" {
+		t.Errorf("expected 'shells', but got '%s'", prefix)
+	}
+
+	hash := []byte{0xff, 0x00, 0xe2, 0x82}
+	ts.PutBytes(hash, "hash")
+	if !ts.ContainsBytes(hash) {
+		t.Errorf("expected binary key %x to be present", hash)
+	}
+	if ts.ContainsBytes(hash[:3]) {
+		t.Errorf("expected binary key %x not to be present", hash[:3])
+	}
+	ts.PutBytes(hash, nil)
+	if ts.ContainsBytes(hash) || ts.Len() != 7 {
+		t.Errorf("expected binary key %x to be deleted", hash)
+	}
+	key := []byte("shells")
+	allocs := testing.AllocsPerRun(100, func() {
+		ts.GetBytes(key)
+		ts.ContainsBytes(key)
+		ts.LongestPrefixOfBytes(key)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, but got %v", allocs)
+	}
+}
+
+func TestTernarySearchKeysWithEmptyPrefix(t *testing.T) {
+	ts := trie.NewTernarySearch()
+	for i, w := range data {
+		ts.Put(w, i)
+	}
+	keys := ts.KeysWithPrefix("")
+	if expected := ts.Keys(); !slices.Equal(keys, expected) {
+		t.Errorf("expected '%v', but got '%v'", expected, keys)
+	}
+	if len(keys) != ts.Len() {
+		t.Errorf("expected %d keys, but got %d", ts.Len(), len(keys))
+	}
+}
+
+func TestTernarySearchPrefixesOf(t *testing.T) {
+	ts := trie.NewTernarySearch()
+	for i, w := range data {
+		ts.Put(w, i)
+	}
+	result := ts.PrefixesOf("shellsort")
+	if len(result) != 2 || result[0] != "she" || result[1] != "shells" {
+		t.Errorf("expected [she shells], but got %v", result)
+	}
+	values := []interface{}{}
+	for _, v := range ts.AllPrefixesOf("shells") {
+		values = append(values, v)
+	}
+	if len(values) != 2 || values[0] != 0 || values[1] != 3 {
+		t.Errorf("expected [0 3], but got %v", values)
+	}
+	if result := ts.PrefixesOf(""); len(result) != 0 {
+		t.Errorf("expected no prefixes, but got %v", result)
+	}
+}
+
+func TestTernarySearchCloneAndSnapshot(t *testing.T) {
+	ts := trie.NewTernarySearch()
+	for i, w := range data {
+		ts.Put(w, i)
+	}
+	clone := ts.Clone()
+	snapshot := ts.Snapshot()
+	ts.Put("she", "changed")
+	snapshot.Put("shellfish", 8)
+
+	for _, c := range []*trie.TernarySearch{clone, snapshot} {
+		if c.Get("she") != 0 {
+			t.Error("expected copy not to see writes to the original")
+		}
+	}
+	if ts.Contains("shellfish") || clone.Contains("shellfish") {
+		t.Error("expected original not to see writes to the snapshot")
+	}
+	if snapshot.Len() != 8 || ts.Len() != 7 {
+		t.Errorf("expected lens 8 and 7, but got %d and %d", snapshot.Len(), ts.Len())
+	}
+}
+
+func TestTernarySearchWalk(t *testing.T) {
+	ts := trie.NewTernarySearch()
+	for i, w := range data {
+		ts.Put(w, i)
+	}
+	var keys []string
+	ts.Walk("s", func(key string, value interface{}) trie.WalkAction {
+		if value != nil {
+			keys = append(keys, key)
+		}
+		switch key {
+		case "she":
+			return trie.WalkSkipSubtree
+		case "shore":
+			return trie.WalkStop
+		}
+		return trie.WalkContinue
+	})
+	expected := []string{"sea", "sells", "she", "shore"}
+	if len(keys) != len(expected) {
+		t.Fatalf("expected %v, but got %v", expected, keys)
+	}
+	for i, key := range expected {
+		if keys[i] != key {
+			t.Errorf("expected '%v', but got '%v'", key, keys[i])
+		}
+	}
+	var visited []string
+	ts.Walk("she", func(key string, value interface{}) trie.WalkAction {
+		visited = append(visited, key)
+		return trie.WalkContinue
+	})
+	if expected := []string{"she", "shel", "shell", "shells"}; !slices.Equal(visited, expected) {
+		t.Errorf("expected %v, but got %v", expected, visited)
+	}
+	// prune at the prefixes that are not keys
+	keys = nil
+	ts.Walk("", func(key string, value interface{}) trie.WalkAction {
+		switch {
+		case key == "sh" || key == "sel":
+			return trie.WalkSkipSubtree
+		case value != nil:
+			keys = append(keys, key)
+		}
+		return trie.WalkContinue
+	})
+	if !slices.Equal(keys, []string{"by", "sea", "the"}) {
+		t.Errorf("expected [by sea the], but got %v", keys)
+	}
+}
+
+func TestTernarySearchBackward(t *testing.T) {
+	ts := trie.NewTernarySearch()
+	for i, w := range data {
+		ts.Put(w, i)
+	}
+	var keys []string
+	for key, value := range ts.Backward() {
+		if ts.Get(key) != value {
+			t.Errorf("expected key '%v' to have value %v, but got %v", key, ts.Get(key), value)
+		}
+		keys = append(keys, key)
+	}
+	expected := ts.Keys()
+	slices.Reverse(expected)
+	if !slices.Equal(keys, expected) {
+		t.Errorf("expected %v, but got %v", expected, keys)
+	}
+	keys = keys[:0]
+	for key := range ts.WithPrefixBackward("she") {
+		keys = append(keys, key)
+	}
+	if !slices.Equal(keys, []string{"shells", "she"}) {
+		t.Errorf("expected [shells she], but got %v", keys)
+	}
+	keys = keys[:0]
+	for key := range ts.WithPrefix("she") {
+		keys = append(keys, key)
+	}
+	if !slices.Equal(keys, []string{"she", "shells"}) {
+		t.Errorf("expected [she shells], but got %v", keys)
+	}
+}
+
+func TestTernarySearchDelete(t *testing.T) {
+	ts := trie.NewTernarySearch()
+	for i, w := range data {
+		ts.Put(w, i)
+	}
+	snapshot := ts.Snapshot()
+	ts.Delete("she")
+	ts.Delete("sh")
+	ts.Delete("sea")
+	if ts.Len() != 5 || ts.Contains("she") || !ts.Contains("shells") || ts.Contains("sea") {
+		t.Errorf("expected 'she' and 'sea' to be deleted, but got %v", ts.Keys())
+	}
+	if n := ts.DeletePrefix("sh"); n != 2 || ts.Len() != 3 {
+		t.Errorf("expected 2 deleted keys and length 3, but got %d and %d", n, ts.Len())
+	}
+	if keys := ts.Keys(); !slices.Equal(keys, []string{"by", "sells", "the"}) {
+		t.Errorf("expected [by sells the], but got %v", keys)
+	}
+	if n := ts.DeletePrefix(""); n != 3 || !ts.IsEmpty() {
+		t.Errorf("expected 3 deleted keys, but got %d", n)
+	}
+	if keys := snapshot.Keys(); len(keys) != 7 || snapshot.Get("she") != 0 {
+		t.Errorf("expected snapshot to be unchanged, but got %v", keys)
+	}
+}
+
+func TestTernarySearchPutNil(t *testing.T) {
+	ts := trie.NewTernarySearch()
+	ts.Put("a", nil)
+	if ts.Len() != 0 || ts.Contains("a") {
+		t.Errorf("expected empty trie, but got %v of length %d", ts.Keys(), ts.Len())
+	}
+	ts.Put("a", 1)
+	ts.Put("ab", 2)
+	ts.Put("a", nil)
+	if ts.Len() != 1 || ts.Contains("a") {
+		t.Errorf("expected only 'ab', but got %v of length %d", ts.Keys(), ts.Len())
+	}
+	ts.Delete("ab")
+	if ts.Len() != 0 || !ts.IsEmpty() {
+		t.Errorf("expected empty trie, but got %v of length %d", ts.Keys(), ts.Len())
+	}
+}
@@ -1,15 +1,27 @@
 package trie // import "kkn.fi/trie"
 
+import (
+	"iter"
+	"sync/atomic"
+)
+
 const r = 256 // extended ascii
 
+// generations is the last generation given to a trie. Nodes are shared
+// between tries of different generations.
+var generations uint64
+
 type (
 	// r-way trie node
 	node struct {
 		next     []*node
-		isString bool // isWord
+		isString bool   // isWord
+		key      string // original spelling of a normalized key
+		gen      uint64 // generation of the trie that owns the node
+		size     int    // number of keys in the subtrie
 	}
 	// Trie represents an ordered set of strings over
-	// the extended ASCII alphabet.
+	// an alphabet, by default the extended ASCII alphabet.
 	// It supports the usual Add, Contains, and Delete
 	// functions. It also provides character-based functions for
 	// finding the string in the set that is the longest prefix
@@ -17,65 +29,184 @@ type (
 	// start with a given prefix, and finding all strings in the set
 	// that match a given pattern.
 	//
-	// This implementation uses a 256-way trie.
+	// Keys can also be given as byte slices with the *Bytes functions. Each
+	// byte of such a key is one character, so arbitrary binary keys can be
+	// stored. A string key and a byte slice key are equal when they consist
+	// of the same ASCII characters.
+	//
+	// The keys can be normalized with WithNormalizer, for example to make the
+	// set case-insensitive. The functions that list keys return the original
+	// spelling of each key.
+	//
+	// This implementation uses an R-way trie, where R is the radix of the
+	// alphabet (256 by default). Keys with characters outside the alphabet
+	// cannot be added and are never contained in the set.
+	//
+	// Snapshot returns a copy of the set in constant time. The copy shares
+	// the nodes with the set, and a write to either one copies only the nodes
+	// on the path of the written key.
 	// The Add, Contains, Delete, and
 	// LongestPrefixOf functions take time proportional to the length
 	// of the key (in the worst case). Construction takes constant time.
 	Trie struct {
-		root   *node
-		length int
+		root      *node
+		length    int
+		alphabet  Alphabet
+		normalize Normalizer
+		gen       uint64 // nodes of other generations are shared
+		writes    uint64 // number of node writes, for detecting conflicts
 	}
 	stringQueue []string
 )
 
 // New returns an empty trie.
-func New() *Trie {
-	return &Trie{}
+func New(opts ...Option) *Trie {
+	c := newConfig(opts)
+	return &Trie{
+		alphabet:  c.alphabet,
+		normalize: c.normalize,
+	}
+}
+
+// Alphabet returns the alphabet of the keys in the set.
+func (t *Trie) Alphabet() Alphabet {
+	if t.alphabet == nil {
+		return ExtendedASCII
+	}
+	return t.alphabet
 }
 
 // Contains returns true if the set contains key and false otherwise.
 func (t *Trie) Contains(key string) bool {
-	x := t.get(t.root, []rune(key), 0)
+	x := t.get(t.root, t.normalized(key))
 	if x == nil {
 		return false
 	}
 	return x.isString
 }
 
-func (t *Trie) get(x *node, key []rune, d int) *node {
+// ContainsBytes returns true if the set contains key and false otherwise.
+func (t *Trie) ContainsBytes(key []byte) bool {
+	x := t.getBytes(t.root, key)
 	if x == nil {
-		return nil
+		return false
 	}
-	if d == len(key) {
-		return x
+	return x.isString
+}
+
+// returns key in the normalized form used for storage and lookup
+func (t *Trie) normalized(key string) string {
+	if t.normalize == nil {
+		return key
+	}
+	return t.normalize(key)
+}
+
+// returns the node of the subtrie rooted at x corresponding to key
+func (t *Trie) get(x *node, key string) *node {
+	a := t.Alphabet()
+	for _, c := range key {
+		if x == nil {
+			return nil
+		}
+		x = t.next(a, x, c)
+	}
+	return x
+}
+
+func (t *Trie) getBytes(x *node, key []byte) *node {
+	a := t.Alphabet()
+	for _, c := range key {
+		if x == nil {
+			return nil
+		}
+		x = t.next(a, x, rune(c))
 	}
-	c := key[d]
-	return t.get(x.next[c], key, d+1)
+	return x
+}
+
+// returns the child of x for character c or nil if there is none
+func (t *Trie) next(a Alphabet, x *node, c rune) *node {
+	i, ok := a.ToIndex(c)
+	if !ok {
+		return nil
+	}
+	return x.next[i]
 }
 
 // Add adds a key to the set if not present.
-// If key is empty function will silently return
-func (t *Trie) Add(key string) {
-	if key == "" {
-		return
+// If key is empty function will silently return.
+// It returns an *AlphabetError if key has characters outside the alphabet.
+func (t *Trie) Add(key string) error {
+	norm := t.normalized(key)
+	if norm == "" {
+		return nil
+	}
+	indices, err := toIndices(t.Alphabet(), norm)
+	if err != nil {
+		return err
+	}
+	t.root = t.add(t.root, indices, 0, key)
+	return nil
+}
+
+// AddBytes adds a key to the set if not present.
+// If key is empty function will silently return.
+// It returns an *AlphabetError if key has characters outside the alphabet.
+func (t *Trie) AddBytes(key []byte) error {
+	if len(key) == 0 {
+		return nil
+	}
+	indices, err := bytesToIndices(t.Alphabet(), key)
+	if err != nil {
+		return err
+	}
+	t.root = t.add(t.root, indices, 0, "")
+	return nil
+}
+
+func (t *Trie) newNode() *node {
+	t.writes++
+	return &node{
+		next: make([]*node, t.Alphabet().Radix()),
+		gen:  t.gen,
 	}
-	t.root = t.add(t.root, []rune(key), 0)
 }
 
-func (t *Trie) add(x *node, key []rune, d int) *node {
+// returns x, or a copy of x if x is shared with a snapshot
+func (t *Trie) mutable(x *node) *node {
+	t.writes++
+	if x.gen == t.gen {
+		return x
+	}
+	c := *x
+	c.next = append([]*node(nil), x.next...)
+	c.gen = t.gen
+	return &c
+}
+
+// adds key to the subtrie rooted at x, remembering spelling as the
+// original spelling of key if the set is normalized
+func (t *Trie) add(x *node, key []int, d int, spelling string) *node {
 	if x == nil {
-		x = &node{
-			next: make([]*node, r),
-		}
+		x = t.newNode()
+	} else {
+		x = t.mutable(x)
 	}
 	if d == len(key) {
 		if !x.isString {
 			t.length++
+			x.size++
+			if t.normalize != nil {
+				x.key = spelling
+			}
 		}
 		x.isString = true
 	} else {
 		c := key[d]
-		x.next[c] = t.add(x.next[c], key, d+1)
+		n := t.length
+		x.next[c] = t.add(x.next[c], key, d+1, spelling)
+		x.size += t.length - n
 	}
 	return x
 }
@@ -93,7 +224,8 @@ func (t *Trie) IsEmpty() bool {
 // KeysWithPrefix returns all the keys in the set that match prefix.
 func (t *Trie) KeysWithPrefix(prefix string) []string {
 	results := &stringQueue{}
-	x := t.get(t.root, []rune(prefix), 0)
+	prefix = t.normalized(prefix)
+	x := t.get(t.root, prefix)
 	t.collect(x, []rune(prefix), results)
 	return results.slice()
 }
@@ -103,20 +235,199 @@ func (t *Trie) collect(x *node, prefix []rune, results *stringQueue) {
 		return
 	}
 	if x.isString {
-		results.enqueue(string(prefix))
+		results.enqueue(x.spelling(prefix))
+	}
+	a := t.Alphabet()
+	for c, next := range x.next {
+		prefix = append(prefix, a.ToChar(c))
+		t.collect(next, prefix, results)
+		prefix = prefix[0 : len(prefix)-1]
+	}
+}
+
+// Walk calls fn for each nonempty prefix of the keys in the set that starts
+// with prefix, in the order of Keys, with each prefix before the longer ones.
+// The isKey argument tells whether the prefix is a key in the set. The action
+// returned by fn tells whether to continue, to skip the prefixes that start
+// with the visited one, or to stop the walk, so a search can be pruned at any
+// prefix, for example to bound its depth.
+func (t *Trie) Walk(prefix string, fn func(key string, isKey bool) WalkAction) {
+	prefix = t.normalized(prefix)
+	x := t.get(t.root, prefix)
+	t.walk(x, []rune(prefix), fn)
+}
+
+// walks the subtrie rooted at x, returning false if the walk was stopped
+func (t *Trie) walk(x *node, prefix []rune, fn func(key string, isKey bool) WalkAction) bool {
+	if x == nil {
+		return true
 	}
-	for c := 0; c < r; c++ {
-		prefix = append(prefix, rune(c))
-		t.collect(x.next[c], prefix, results)
+	if len(prefix) > 0 {
+		key := string(prefix)
+		if x.isString {
+			key = x.spelling(prefix)
+		}
+		switch fn(key, x.isString) {
+		case WalkStop:
+			return false
+		case WalkSkipSubtree:
+			return true
+		}
+	}
+	a := t.Alphabet()
+	for c, next := range x.next {
+		if next == nil {
+			continue
+		}
+		prefix = append(prefix, a.ToChar(c))
+		if !t.walk(next, prefix, fn) {
+			return false
+		}
 		prefix = prefix[0 : len(prefix)-1]
 	}
+	return true
+}
+
+// KeysWithPrefixAfter returns a page of at most limit keys in the set that
+// start with prefix, and a continuation token for the next page. The page
+// starts after the key of token, or with the first key if token is empty.
+// The returned token is empty when there are no more keys. A limit of zero or
+// less means no limit. Resuming from a token takes time proportional to the
+// length of its key instead of the number of keys before it.
+func (t *Trie) KeysWithPrefixAfter(prefix, token string, limit int) ([]string, string, error) {
+	var after []int
+	if token != "" {
+		chars, err := decodeToken(token)
+		if err != nil {
+			return nil, "", err
+		}
+		if after, err = toIndices(t.Alphabet(), string(chars)); err != nil {
+			return nil, "", ErrInvalidToken
+		}
+	}
+	results := new(stringQueue)
+	prefix = t.normalized(prefix)
+	p, err := toIndices(t.Alphabet(), prefix)
+	if err != nil {
+		return results.slice(), "", nil
+	}
+	var last []rune
+	next := ""
+	visit := func(chars []rune, x *node) bool {
+		if limit > 0 && len(*results) == limit {
+			next = encodeToken(last)
+			return false
+		}
+		results.enqueue(x.spelling(chars))
+		last = append(last[:0], chars...)
+		return true
+	}
+	x := t.get(t.root, prefix)
+	rest, order := afterPrefix(p, after)
+	if token == "" || order < 0 {
+		t.visitKeys(x, []rune(prefix), 0, true, visit)
+	} else if order == 0 {
+		t.visitKeysAfter(x, []rune(prefix), rest, visit)
+	}
+	return results.slice(), next, nil
+}
+
+// visits the keys in the subtrie rooted at x, starting from the child at
+// index from, and x itself if self is true, until visit returns false
+func (t *Trie) visitKeys(x *node, chars []rune, from int, self bool, visit func([]rune, *node) bool) bool {
+	if x == nil {
+		return true
+	}
+	if self && x.isString && !visit(chars, x) {
+		return false
+	}
+	a := t.Alphabet()
+	for c := from; c < len(x.next); c++ {
+		if x.next[c] == nil {
+			continue
+		}
+		if !t.visitKeys(x.next[c], append(chars, a.ToChar(c)), 0, true, visit) {
+			return false
+		}
+	}
+	return true
+}
+
+// visits the keys in the subtrie rooted at x that are after the key with
+// the character indices after, until visit returns false
+func (t *Trie) visitKeysAfter(x *node, chars []rune, after []int, visit func([]rune, *node) bool) bool {
+	if x == nil {
+		return true
+	}
+	if len(after) == 0 {
+		return t.visitKeys(x, chars, 0, false, visit)
+	}
+	c := after[0]
+	if !t.visitKeysAfter(x.next[c], append(chars, t.Alphabet().ToChar(c)), after[1:], visit) {
+		return false
+	}
+	return t.visitKeys(x, chars, c+1, false, visit)
+}
+
+// All returns an iterator over the keys in the set in the order of Keys.
+func (t *Trie) All() iter.Seq[string] {
+	return t.WithPrefix("")
+}
+
+// WithPrefix returns an iterator over the keys in the set that start with
+// prefix, in the order of Keys.
+func (t *Trie) WithPrefix(prefix string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		prefix := t.normalized(prefix)
+		x := t.get(t.root, prefix)
+		t.visitKeys(x, []rune(prefix), 0, true, func(chars []rune, x *node) bool {
+			return yield(x.spelling(chars))
+		})
+	}
+}
+
+// Backward returns an iterator over the keys in the set in the
+// reverse order of Keys.
+func (t *Trie) Backward() iter.Seq[string] {
+	return t.WithPrefixBackward("")
+}
+
+// WithPrefixBackward returns an iterator over the keys in the set that
+// start with prefix, in the reverse order of Keys. The keys are not
+// collected before iterating.
+func (t *Trie) WithPrefixBackward(prefix string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		prefix := t.normalized(prefix)
+		x := t.get(t.root, prefix)
+		t.visitKeysBackward(x, []rune(prefix), func(chars []rune, x *node) bool {
+			return yield(x.spelling(chars))
+		})
+	}
+}
+
+// visits the keys in the subtrie rooted at x in reverse order, until visit
+// returns false
+func (t *Trie) visitKeysBackward(x *node, chars []rune, visit func([]rune, *node) bool) bool {
+	if x == nil {
+		return true
+	}
+	a := t.Alphabet()
+	for c := len(x.next) - 1; c >= 0; c-- {
+		if x.next[c] == nil {
+			continue
+		}
+		if !t.visitKeysBackward(x.next[c], append(chars, a.ToChar(c)), visit) {
+			return false
+		}
+	}
+	return !x.isString || visit(chars, x)
 }
 
 // KeysThatMatch all of the keys in the set that match pattern,
 // where '.' symbol is treated as a wildcard character.
 func (t *Trie) KeysThatMatch(pattern string) []string {
 	results := new(stringQueue)
-	t.collectWildcard(t.root, []rune(""), []rune(pattern), results)
+	t.collectWildcard(t.root, []rune(""), []rune(t.normalized(pattern)), results)
 	return results.slice()
 }
 
@@ -126,77 +437,197 @@ func (t *Trie) collectWildcard(x *node, prefix, pattern []rune, results *stringQ
 	}
 	d := len(prefix)
 	if d == len(pattern) && x.isString {
-		results.enqueue(string(prefix))
+		results.enqueue(x.spelling(prefix))
 	}
 	if d == len(pattern) {
 		return
 	}
+	a := t.Alphabet()
 	c := pattern[d]
 	if c == '.' {
-		for ch := 0; ch < r; ch++ {
-			prefix = append(prefix, rune(ch))
-			t.collectWildcard(x.next[ch], prefix, pattern, results)
+		for ch, next := range x.next {
+			prefix = append(prefix, a.ToChar(ch))
+			t.collectWildcard(next, prefix, pattern, results)
 			prefix = prefix[0 : len(prefix)-1]
 		}
-	} else {
+	} else if next := t.next(a, x, c); next != nil {
 		prefix = append(prefix, c)
-		t.collectWildcard(x.next[c], prefix, pattern, results)
+		t.collectWildcard(next, prefix, pattern, results)
 	}
 }
 
 // LongestPrefixOf Returns the string in the set that is the
 // longest prefix of query, or an empty string, if no such string.
 func (t *Trie) LongestPrefixOf(query string) string {
-	length := t.longestPrefixOf(t.root, []rune(query), 0, -1)
-	if length == -1 {
-		return ""
+	a := t.Alphabet()
+	query = t.normalized(query)
+	var match *node
+	length, x := 0, t.root
+	for i, c := range query {
+		if x == nil {
+			break
+		}
+		if x.isString {
+			length, match = i, x
+		}
+		x = t.next(a, x, c)
+	}
+	if x != nil && x.isString {
+		length, match = len(query), x
+	}
+	if match != nil && match.key != "" {
+		return match.key
 	}
-	return string([]rune(query)[:length])
+	return query[:length]
 }
 
-// returns the length of the longest string key in the subtrie
-// rooted at x that is a prefix of the query string,
-// assuming the first d character match and we have already
-// found a prefix match of length length
-func (t *Trie) longestPrefixOf(x *node, query []rune, d, length int) int {
-	if x == nil {
-		return length
+// LongestPrefixOfBytes returns the key in the set that is the longest
+// prefix of query, or an empty slice, if no such key. The returned slice
+// shares its storage with query.
+func (t *Trie) LongestPrefixOfBytes(query []byte) []byte {
+	a := t.Alphabet()
+	length, x := 0, t.root
+	for i, c := range query {
+		if x == nil {
+			return query[:length]
+		}
+		if x.isString {
+			length = i
+		}
+		x = t.next(a, x, rune(c))
 	}
-	if x.isString {
-		length = d
+	if x != nil && x.isString {
+		length = len(query)
+	}
+	return query[:length]
+}
+
+// PrefixesOf returns all the keys in the set that are prefixes of query,
+// from the shortest to the longest.
+func (t *Trie) PrefixesOf(query string) []string {
+	results := new(stringQueue)
+	for key := range t.AllPrefixesOf(query) {
+		results.enqueue(key)
 	}
-	if d == len(query) {
-		return length
+	return results.slice()
+}
+
+// AllPrefixesOf returns an iterator over the keys in the set that are
+// prefixes of query, from the shortest to the longest. The keys are found
+// in one walk down the path of query.
+func (t *Trie) AllPrefixesOf(query string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		a := t.Alphabet()
+		query := t.normalized(query)
+		x := t.root
+		for i, c := range query {
+			if x == nil {
+				return
+			}
+			if x.isString && !yield(x.spellingOf(query[:i])) {
+				return
+			}
+			x = t.next(a, x, c)
+		}
+		if x != nil && x.isString {
+			yield(x.spellingOf(query))
+		}
 	}
-	c := query[d]
-	return t.longestPrefixOf(x.next[c], query, d+1, length)
 }
 
 // Delete deletes the key from the set if it is present.
 func (t *Trie) Delete(key string) {
-	t.root = t.delete(t.root, []rune(key), 0)
+	indices, err := toIndices(t.Alphabet(), t.normalized(key))
+	if err != nil {
+		return
+	}
+	t.root = t.delete(t.root, indices, 0)
 }
 
-func (t *Trie) delete(x *node, key []rune, d int) *node {
+// DeleteBytes deletes the key from the set if it is present.
+func (t *Trie) DeleteBytes(key []byte) {
+	indices, err := bytesToIndices(t.Alphabet(), key)
+	if err != nil {
+		return
+	}
+	t.root = t.delete(t.root, indices, 0)
+}
+
+func (t *Trie) delete(x *node, key []int, d int) *node {
 	if x == nil {
 		return nil
 	}
 	if d == len(key) {
-		if x.isString {
-			t.length--
+		if !x.isString {
+			return x
 		}
+		t.length--
+		x = t.mutable(x)
 		x.isString = false
+		x.key = ""
+		x.size--
 	} else {
 		c := key[d]
-		x.next[c] = t.delete(x.next[c], key, d+1)
+		n := t.length
+		next := t.delete(x.next[c], key, d+1)
+		if n == t.length {
+			return x
+		}
+		x = t.mutable(x)
+		x.next[c] = next
+		x.size--
 	}
 
 	// remove subtrie rooted at x if it is completely empty
 	if x.isString {
 		return x
 	}
-	for c := 0; c < r; c++ {
-		if x.next[c] != nil {
+	for _, next := range x.next {
+		if next != nil {
+			return x
+		}
+	}
+	return nil
+}
+
+// DeletePrefix deletes the keys that start with prefix from the set and
+// returns the number of keys deleted. The subtrie of prefix is detached in
+// time proportional to the length of prefix.
+func (t *Trie) DeletePrefix(prefix string) int {
+	indices, err := toIndices(t.Alphabet(), t.normalized(prefix))
+	if err != nil {
+		return 0
+	}
+	n := 0
+	t.root = t.deletePrefix(t.root, indices, 0, &n)
+	t.length -= n
+	t.writes++
+	return n
+}
+
+// detaches the subtrie of prefix from the subtrie rooted at x, storing the
+// number of keys in it to n
+func (t *Trie) deletePrefix(x *node, prefix []int, d int, n *int) *node {
+	if x == nil {
+		return nil
+	}
+	if d == len(prefix) {
+		*n = x.size
+		return nil
+	}
+	c := prefix[d]
+	next := t.deletePrefix(x.next[c], prefix, d+1, n)
+	if *n == 0 {
+		return x
+	}
+	x = t.mutable(x)
+	x.next[c] = next
+	x.size -= *n
+	if x.isString {
+		return x
+	}
+	for _, next := range x.next {
+		if next != nil {
 			return x
 		}
 	}
@@ -208,6 +639,58 @@ func (t *Trie) Keys() []string {
 	return t.KeysWithPrefix("")
 }
 
+// Clone returns a deep copy of the set.
+func (t *Trie) Clone() *Trie {
+	c := *t
+	c.gen = newGeneration()
+	c.root = c.clone(t.root)
+	return &c
+}
+
+func (t *Trie) clone(x *node) *node {
+	if x == nil {
+		return nil
+	}
+	c := *x
+	c.next = make([]*node, len(x.next))
+	for i, next := range x.next {
+		c.next[i] = t.clone(next)
+	}
+	c.gen = t.gen
+	return &c
+}
+
+// Snapshot returns a copy of the set in constant time. The nodes are shared
+// until they are written to by either the set or the snapshot. A snapshot
+// can be read while the set is written to.
+func (t *Trie) Snapshot() *Trie {
+	s := *t
+	s.gen = newGeneration()
+	t.gen = newGeneration()
+	return &s
+}
+
+// newGeneration returns a generation that no trie has had before.
+func newGeneration() uint64 {
+	return atomic.AddUint64(&generations, 1)
+}
+
+// returns the original spelling of the key of x, given its characters
+func (x *node) spelling(chars []rune) string {
+	if x.key != "" {
+		return x.key
+	}
+	return string(chars)
+}
+
+// returns the original spelling of the key of x, given the key
+func (x *node) spellingOf(key string) string {
+	if x.key != "" {
+		return x.key
+	}
+	return key
+}
+
 func (q *stringQueue) enqueue(x string) {
 	*q = append(*q, x)
 }
@@ -1,6 +1,7 @@
 package trie_test
 
 import (
+	"slices"
 	"testing"
 
 	"kkn.fi/trie"
@@ -159,3 +160,218 @@ func BenchmarkTrieKeysWithPrefix(b *testing.B) {
 		tmp2 = st.KeysWithPrefix("shor")
 	}
 }
+
+func TestTrieBytes(t *testing.T) {
+	st := trie.New()
+	for _, w := range data {
+		st.AddBytes([]byte(w))
+	}
+	if !st.Contains("shore") || !st.ContainsBytes([]byte("shore")) {
+		t.Errorf("expected set to contain 'shore'")
+	}
+	prefix := st.LongestPrefixOfBytes([]byte("shellsort"))
+	if string(prefix) != "shells" {
+		t.Errorf("expected 'shells', but got '%s'", prefix)
+	}
+	key := []byte{0x00, 0xff}
+	st.AddBytes(key)
+	if !st.ContainsBytes(key) {
+		t.Errorf("expected set to contain %x", key)
+	}
+	st.DeleteBytes(key)
+	if st.ContainsBytes(key) || st.Len() != len(data)-1 {
+		t.Errorf("expected %x to be deleted", key)
+	}
+}
+
+func BenchmarkTrieContains(b *testing.B) {
+	st := trie.New()
+	for _, w := range data {
+		st.Add(w)
+	}
+	for n := 0; n < b.N; n++ {
+		st.Contains("shells")
+	}
+}
+
+func TestTrieAlphabet(t *testing.T) {
+	st := trie.New(trie.WithAlphabet(trie.LowerCase))
+	for _, w := range data {
+		if err := st.Add(w); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if err := st.Add("Shore"); err == nil {
+		t.Error("expected error for key outside alphabet")
+	}
+	if st.Len() != len(data)-1 {
+		t.Errorf("expected %d, but got %d", len(data)-1, st.Len())
+	}
+	if !st.Contains("shore") || st.Contains("Shore") {
+		t.Error("expected only 'shore' to be contained")
+	}
+	if result := st.KeysWithPrefix("sh"); len(result) != 3 {
+		t.Errorf("expected 3 keys, but got %v", result)
+	}
+}
+
+func TestTrieNormalizer(t *testing.T) {
+	st := trie.New(trie.WithNormalizer(trie.FoldCase, trie.StripDiacritics))
+	st.Add("Crème Brûlée")
+	st.Add("CREME BRULEE")
+	if st.Len() != 1 {
+		t.Errorf("expected len 1, but got %d", st.Len())
+	}
+	if !st.Contains("creme brulee") {
+		t.Error("expected set to contain 'creme brulee'")
+	}
+	keys := st.KeysWithPrefix("CRÈME")
+	if len(keys) != 1 || keys[0] != "Crème Brûlée" {
+		t.Errorf("expected [Crème Brûlée], but got %v", keys)
+	}
+}
+
+func TestTriePrefixesOf(t *testing.T) {
+	st := trie.New()
+	for _, w := range []string{"a", "ab", "abc", "abd", "b"} {
+		st.Add(w)
+	}
+	expected := []string{"a", "ab", "abc"}
+	result := st.PrefixesOf("abcd")
+	if len(result) != len(expected) {
+		t.Fatalf("expected %v, but got %v", expected, result)
+	}
+	for i, key := range expected {
+		if result[i] != key {
+			t.Errorf("expected '%v', but got '%v'", key, result[i])
+		}
+	}
+	if result := st.PrefixesOf("xyz"); len(result) != 0 {
+		t.Errorf("expected no prefixes, but got %v", result)
+	}
+	for key := range st.AllPrefixesOf("abc") {
+		if key != "a" {
+			t.Errorf("expected 'a', but got '%v'", key)
+		}
+		break
+	}
+}
+
+func TestTrieCloneAndSnapshot(t *testing.T) {
+	st := trie.New()
+	for _, w := range data {
+		st.Add(w)
+	}
+	clone := st.Clone()
+	snapshot := st.Snapshot()
+	st.Delete("shells")
+	st.Add("seashell")
+	snapshot.Add("shellfish")
+
+	for _, c := range []*trie.Trie{clone, snapshot} {
+		if !c.Contains("shells") || c.Contains("seashell") {
+			t.Error("expected copy not to see writes to the original")
+		}
+	}
+	if st.Contains("shellfish") || clone.Contains("shellfish") {
+		t.Error("expected original not to see writes to the snapshot")
+	}
+	if st.Len() != 7 || clone.Len() != 7 || snapshot.Len() != 8 {
+		t.Errorf("expected lens 7, 7 and 8, but got %d, %d and %d", st.Len(), clone.Len(), snapshot.Len())
+	}
+}
+
+func TestTrieWalk(t *testing.T) {
+	st := trie.New()
+	for _, w := range data {
+		st.Add(w)
+	}
+	var keys []string
+	st.Walk("s", func(key string, isKey bool) trie.WalkAction {
+		if isKey {
+			keys = append(keys, key)
+		}
+		switch key {
+		case "she":
+			return trie.WalkSkipSubtree
+		case "shore":
+			return trie.WalkStop
+		}
+		return trie.WalkContinue
+	})
+	expected := []string{"sea", "sells", "she", "shore"}
+	if len(keys) != len(expected) {
+		t.Fatalf("expected %v, but got %v", expected, keys)
+	}
+	for i, key := range expected {
+		if keys[i] != key {
+			t.Errorf("expected '%v', but got '%v'", key, keys[i])
+		}
+	}
+	// bound the depth of the walk
+	keys = nil
+	st.Walk("", func(key string, isKey bool) trie.WalkAction {
+		if isKey {
+			keys = append(keys, key)
+		}
+		if len(key) == 2 {
+			return trie.WalkSkipSubtree
+		}
+		return trie.WalkContinue
+	})
+	if !slices.Equal(keys, []string{"by"}) {
+		t.Errorf("expected [by], but got %v", keys)
+	}
+}
+
+func TestTrieBackward(t *testing.T) {
+	st := trie.New()
+	for _, w := range data {
+		st.Add(w)
+	}
+	keys := slices.Collect(st.Backward())
+	expected := st.Keys()
+	slices.Reverse(expected)
+	if !slices.Equal(keys, expected) {
+		t.Errorf("expected %v, but got %v", expected, keys)
+	}
+	if keys := slices.Collect(st.All()); !slices.Equal(keys, st.Keys()) {
+		t.Errorf("expected %v, but got %v", st.Keys(), keys)
+	}
+	keys = slices.Collect(st.WithPrefixBackward("sh"))
+	if !slices.Equal(keys, []string{"shore", "shells", "she"}) {
+		t.Errorf("expected [shore shells she], but got %v", keys)
+	}
+	for key := range st.WithPrefixBackward("s") {
+		if key != "shore" {
+			t.Errorf("expected 'shore', but got '%v'", key)
+		}
+		break
+	}
+}
+
+func TestTrieDeletePrefix(t *testing.T) {
+	tr := trie.New()
+	for _, w := range data {
+		tr.Add(w)
+	}
+	snapshot := tr.Snapshot()
+	if n := tr.DeletePrefix("sh"); n != 3 || tr.Len() != 4 {
+		t.Errorf("expected 3 deleted keys and length 4, but got %d and %d", n, tr.Len())
+	}
+	if keys := tr.Keys(); !slices.Equal(keys, []string{"by", "sea", "sells", "the"}) {
+		t.Errorf("expected [by sea sells the], but got %v", keys)
+	}
+	if n := tr.DeletePrefix("x"); n != 0 || tr.Len() != 4 {
+		t.Errorf("expected no deleted keys, but got %d", n)
+	}
+	if n := tr.DeletePrefix("b"); n != 1 || tr.LongestPrefixOf("byte") != "" {
+		t.Errorf("expected 'by' to be deleted, but got %d", n)
+	}
+	if n := tr.DeletePrefix(""); n != 3 || !tr.IsEmpty() {
+		t.Errorf("expected 3 deleted keys, but got %d", n)
+	}
+	if snapshot.Len() != 7 || len(snapshot.KeysWithPrefix("sh")) != 3 {
+		t.Errorf("expected snapshot to be unchanged, but got %v", snapshot.Keys())
+	}
+}
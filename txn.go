@@ -0,0 +1,185 @@
+package trie // import "kkn.fi/trie"
+
+import "errors"
+
+var (
+	// ErrConflict is returned when a transaction is committed after the
+	// trie it was begun on has been written to.
+	ErrConflict = errors.New("trie: transaction conflicts with a write")
+	// ErrTxnDone is returned when a transaction is used after it has been
+	// committed or rolled back.
+	ErrTxnDone = errors.New("trie: transaction is already committed or rolled back")
+)
+
+type (
+	// TrieTxn is a transaction of a Trie. The writes of a transaction are
+	// staged on a snapshot of the trie, and they are all applied to the trie
+	// at once by Commit or discarded by Rollback. The trie is not changed
+	// until Commit, so the trie has either none or all of the writes of the
+	// transaction.
+	//
+	// Like the other writes, Commit must not run concurrently with the
+	// readers of the trie. A goroutine can read a snapshot of the trie while
+	// another goroutine commits.
+	TrieTxn struct {
+		trie   *Trie
+		work   *Trie
+		writes uint64 // writes of trie when the transaction began
+		done   bool
+	}
+	// SymbolTableTxn is a transaction of a SymbolTable. The writes of a
+	// transaction are staged on a snapshot of the symbol table, and they are
+	// all applied to the symbol table at once by Commit or discarded by
+	// Rollback. The symbol table is not changed until Commit, so the symbol
+	// table has either none or all of the writes of the transaction. The
+	// watchers of the symbol table are notified of the writes on Commit.
+	//
+	// Like the other writes, Commit must not run concurrently with the
+	// readers of the symbol table. A goroutine can read a snapshot of the
+	// symbol table while another goroutine commits, which is what
+	// VersionedSymbolTable does.
+	SymbolTableTxn struct {
+		table   *SymbolTable
+		work    *SymbolTable
+		writes  uint64 // writes of table when the transaction began
+		changes []txnChange
+		done    bool
+	}
+	txnChange struct {
+		key      string
+		old, new interface{}
+	}
+)
+
+// Begin begins a transaction of the trie. Commit fails with ErrConflict if
+// the trie is written to before the transaction is committed.
+func (t *Trie) Begin() *TrieTxn {
+	return &TrieTxn{
+		trie:   t,
+		work:   t.Snapshot(),
+		writes: t.writes,
+	}
+}
+
+// Add stages the adding of key to the set.
+// It returns an *AlphabetError if key has characters outside the alphabet.
+func (tx *TrieTxn) Add(key string) error {
+	if tx.done {
+		return ErrTxnDone
+	}
+	return tx.work.Add(key)
+}
+
+// Delete stages the deleting of key from the set.
+func (tx *TrieTxn) Delete(key string) {
+	if !tx.done {
+		tx.work.Delete(key)
+	}
+}
+
+// Contains returns true if the set contains key with the staged writes.
+func (tx *TrieTxn) Contains(key string) bool {
+	return tx.work.Contains(key)
+}
+
+// Len returns the number of strings in the set with the staged writes.
+func (tx *TrieTxn) Len() int {
+	return tx.work.Len()
+}
+
+// Commit applies the staged writes to the trie. It returns ErrConflict and
+// discards the writes if the trie has been written to since the
+// transaction began.
+func (tx *TrieTxn) Commit() error {
+	if tx.done {
+		return ErrTxnDone
+	}
+	tx.done = true
+	t := tx.trie
+	if t.writes != tx.writes {
+		return ErrConflict
+	}
+	t.root, t.length, t.gen = tx.work.root, tx.work.length, tx.work.gen
+	t.writes++
+	return nil
+}
+
+// Rollback discards the staged writes.
+func (tx *TrieTxn) Rollback() {
+	tx.done = true
+}
+
+// Begin begins a transaction of the symbol table. Commit fails with
+// ErrConflict if the symbol table is written to before the transaction is
+// committed.
+func (t *SymbolTable) Begin() *SymbolTableTxn {
+	return &SymbolTableTxn{
+		table:  t,
+		work:   t.Snapshot(),
+		writes: t.writes,
+	}
+}
+
+// Put stages the putting of the key-value pair. A nil value stages the
+// deleting of key.
+// It returns an *AlphabetError if key has characters outside the alphabet.
+func (tx *SymbolTableTxn) Put(key string, value interface{}) error {
+	if tx.done {
+		return ErrTxnDone
+	}
+	old, _, err := tx.work.Swap(key, value)
+	if err == nil && (old != nil || value != nil) && tx.work.normalized(key) != "" {
+		tx.changes = append(tx.changes, txnChange{key: key, old: old, new: value})
+	}
+	return err
+}
+
+// Delete stages the deleting of key.
+func (tx *SymbolTableTxn) Delete(key string) {
+	tx.Put(key, nil)
+}
+
+// Get returns the value associated with key with the staged writes.
+func (tx *SymbolTableTxn) Get(key string) interface{} {
+	return tx.work.Get(key)
+}
+
+// Contains returns true if the symbol table contains key with the staged
+// writes.
+func (tx *SymbolTableTxn) Contains(key string) bool {
+	return tx.work.Contains(key)
+}
+
+// Len returns the number of keys in the symbol table with the staged
+// writes.
+func (tx *SymbolTableTxn) Len() int {
+	return tx.work.Len()
+}
+
+// Commit applies the staged writes to the symbol table. It returns
+// ErrConflict and discards the writes if the symbol table has been written
+// to since the transaction began.
+func (tx *SymbolTableTxn) Commit() error {
+	if tx.done {
+		return ErrTxnDone
+	}
+	tx.done = true
+	t := tx.table
+	if t.writes != tx.writes {
+		return ErrConflict
+	}
+	t.root, t.length, t.gen = tx.work.root, tx.work.length, tx.work.gen
+	t.writes++
+	if t.watched() {
+		for _, c := range tx.changes {
+			indices, _ := toIndices(t.Alphabet(), t.normalized(c.key))
+			t.notify(indices, c.key, c.old, c.new)
+		}
+	}
+	return nil
+}
+
+// Rollback discards the staged writes.
+func (tx *SymbolTableTxn) Rollback() {
+	tx.done = true
+}
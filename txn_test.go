@@ -0,0 +1,139 @@
+package trie_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+func TestSymbolTableTxn(t *testing.T) {
+	st := trie.NewSymbolTable()
+	for i, w := range data {
+		st.Put(w, i)
+	}
+	events, cancel := st.Watch("s")
+	defer cancel()
+	tx := st.Begin()
+	tx.Put("sea", 10)
+	tx.Put("seashell", 11)
+	tx.Delete("she")
+	tx.Delete("shelf")
+	tx.Put("", 12)
+	if st.Get("sea") != 6 || !st.Contains("she") || st.Len() != 7 {
+		t.Errorf("expected staged writes to be invisible before commit")
+	}
+	if tx.Get("sea") != 10 || tx.Contains("she") || tx.Len() != 7 {
+		t.Errorf("expected staged writes to be visible in transaction, but got %v, %v and %d", tx.Get("sea"), tx.Contains("she"), tx.Len())
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if st.Get("sea") != 10 || st.Get("seashell") != 11 || st.Contains("she") || st.Len() != 7 {
+		t.Errorf("expected writes to be committed, but got %v", st.Keys())
+	}
+	expected := []trie.Event{
+		{Type: trie.EventPut, Key: "sea", Old: 6, New: 10},
+		{Type: trie.EventPut, Key: "seashell", New: 11},
+		{Type: trie.EventDelete, Key: "she", Old: 0},
+	}
+	for _, e := range expected {
+		if got := <-events; got != e {
+			t.Errorf("expected %+v, but got %+v", e, got)
+		}
+	}
+	if err := tx.Put("by", 1); !errors.Is(err, trie.ErrTxnDone) {
+		t.Errorf("expected %v, but got %v", trie.ErrTxnDone, err)
+	}
+	st.Put("by", 13)
+	if st.Get("sea") != 10 || st.Get("by") != 13 {
+		t.Errorf("expected table to be writable after commit")
+	}
+}
+
+func TestSymbolTableTxnRollbackAndConflict(t *testing.T) {
+	st := trie.NewSymbolTable()
+	for i, w := range data {
+		st.Put(w, i)
+	}
+	keys := st.Keys()
+	tx := st.Begin()
+	tx.Delete("sea")
+	tx.Rollback()
+	if err := tx.Commit(); !errors.Is(err, trie.ErrTxnDone) {
+		t.Errorf("expected %v, but got %v", trie.ErrTxnDone, err)
+	}
+	tx = st.Begin()
+	tx.Put("the", 20)
+	st.Put("by", 21)
+	if err := tx.Commit(); !errors.Is(err, trie.ErrConflict) {
+		t.Errorf("expected %v, but got %v", trie.ErrConflict, err)
+	}
+	if !slices.Equal(st.Keys(), keys) || st.Get("the") != 5 || st.Get("by") != 21 {
+		t.Errorf("expected only the write outside the transaction, but got %v", st.Keys())
+	}
+}
+
+func TestTxnConflictABA(t *testing.T) {
+	st := trie.NewSymbolTable()
+	tx := st.Begin()
+	tx.Put("the", 1)
+	// the symbol table is empty again with a nil root
+	st.Put("sea", 2)
+	st.Delete("sea")
+	if err := tx.Commit(); !errors.Is(err, trie.ErrConflict) {
+		t.Errorf("expected %v after emptying, but got %v", trie.ErrConflict, err)
+	}
+
+	for i, w := range data {
+		st.Put(w, i)
+	}
+	snapshot := st.Snapshot()
+	tx = st.Begin()
+	tx.Put("the", 1)
+	// the symbol table has the same root again
+	st.DeletePrefix("")
+	st.Merge(snapshot, nil)
+	if err := tx.Commit(); !errors.Is(err, trie.ErrConflict) {
+		t.Errorf("expected %v after restoring the root, but got %v", trie.ErrConflict, err)
+	}
+
+	tr := trie.New()
+	ttx := tr.Begin()
+	ttx.Add("the")
+	tr.Add("sea")
+	tr.Delete("sea")
+	if err := ttx.Commit(); !errors.Is(err, trie.ErrConflict) {
+		t.Errorf("expected %v after emptying the trie, but got %v", trie.ErrConflict, err)
+	}
+}
+
+func TestTrieTxn(t *testing.T) {
+	tr := trie.New()
+	for _, w := range data {
+		tr.Add(w)
+	}
+	snapshot := tr.Snapshot()
+	tx := tr.Begin()
+	tx.Add("seashell")
+	tx.Delete("by")
+	if tr.Contains("seashell") || !tx.Contains("seashell") || tx.Len() != 7 {
+		t.Errorf("expected staged writes to be visible only in transaction")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if !tr.Contains("seashell") || tr.Contains("by") || tr.Len() != 7 {
+		t.Errorf("expected writes to be committed, but got %v", tr.Keys())
+	}
+	if snapshot.Contains("seashell") || !snapshot.Contains("by") {
+		t.Errorf("expected snapshot to be unchanged, but got %v", snapshot.Keys())
+	}
+	tx = tr.Begin()
+	tx.Add("shell")
+	tr.Delete("sea")
+	if err := tx.Commit(); !errors.Is(err, trie.ErrConflict) || tr.Contains("shell") {
+		t.Errorf("expected %v, but got %v", trie.ErrConflict, err)
+	}
+}
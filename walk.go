@@ -0,0 +1,14 @@
+package trie // import "kkn.fi/trie"
+
+// WalkAction tells a walk how to continue after visiting a prefix.
+type WalkAction int
+
+const (
+	// WalkContinue continues the walk with the next prefix.
+	WalkContinue WalkAction = iota
+	// WalkSkipSubtree continues the walk with the next prefix that does not
+	// start with the visited prefix.
+	WalkSkipSubtree
+	// WalkStop ends the walk.
+	WalkStop
+)
@@ -0,0 +1,154 @@
+package trie // import "kkn.fi/trie"
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// EventType tells how a key of a watched SymbolTable changed.
+type EventType int
+
+const (
+	// EventPut means that a value was put to a key.
+	EventPut EventType = iota
+	// EventDelete means that a key was deleted.
+	EventDelete
+)
+
+func (e EventType) String() string {
+	switch e {
+	case EventPut:
+		return "put"
+	case EventDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// WatchBuffer is the number of events that a watcher buffers. A watcher
+// whose buffer is full when an event is sent is disconnected.
+const WatchBuffer = 64
+
+type (
+	// Event is a change of a key of a watched SymbolTable. Old is nil if
+	// the key was added and New is nil if it was deleted.
+	Event struct {
+		Type EventType
+		Key  string
+		Old  interface{}
+		New  interface{}
+	}
+	watcher struct {
+		prefix []int
+		events chan Event
+	}
+	watchers struct {
+		mu     sync.Mutex
+		list   []*watcher
+		active atomic.Bool // true if list is not empty
+	}
+)
+
+// Watch returns a channel of the events of the keys that start with prefix,
+// and a function that cancels the watch and closes the channel. The events
+// are sent when the symbol table is written to, in the order of the writes.
+//
+// A watcher buffers WatchBuffer events. The writes never wait for a slow
+// watcher: if the buffer of a watcher is full, the watcher is disconnected
+// and its channel is closed, so a watcher that sees its channel closed
+// before it cancels the watch has missed events and should watch again.
+// Watch and the cancel function can be called from any goroutine, also
+// while the symbol table is written to. The symbol table must have been
+// returned by NewSymbolTable or another function of this package, and Watch
+// panics if it is the zero value.
+func (t *SymbolTable) Watch(prefix string) (<-chan Event, func()) {
+	if t.watch == nil {
+		panic("trie: Watch of a SymbolTable that was not created with NewSymbolTable")
+	}
+	w := &watcher{
+		events: make(chan Event, WatchBuffer),
+	}
+	p, err := toIndices(t.Alphabet(), t.normalized(prefix))
+	if err != nil {
+		// no key starts with prefix
+		return w.events, sync.OnceFunc(func() { close(w.events) })
+	}
+	w.prefix = p
+	t.watch.add(w)
+	return w.events, func() { t.watch.remove(w) }
+}
+
+func (ws *watchers) add(w *watcher) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.list = append(ws.list, w)
+	ws.active.Store(true)
+}
+
+// removes w and closes its channel if w is still watching
+func (ws *watchers) remove(w *watcher) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for i, v := range ws.list {
+		if v == w {
+			ws.list = append(ws.list[:i], ws.list[i+1:]...)
+			ws.active.Store(len(ws.list) > 0)
+			close(w.events)
+			return
+		}
+	}
+}
+
+// returns true if the symbol table has watchers
+func (t *SymbolTable) watched() bool {
+	return t.watch != nil && t.watch.active.Load()
+}
+
+// notify sends the change of the key with character indices key and the
+// given spelling to the watchers of its prefixes
+func (t *SymbolTable) notify(key []int, spelling string, old, new interface{}) {
+	if !t.watched() || old == nil && new == nil {
+		return
+	}
+	if spelling == "" {
+		spelling = string(toChars(t.Alphabet(), key))
+	}
+	e := Event{Type: EventPut, Key: spelling, Old: old, New: new}
+	if new == nil {
+		e.Type = EventDelete
+	}
+	ws := t.watch
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	list := ws.list[:0]
+	for _, w := range ws.list {
+		if !hasPrefix(key, w.prefix) {
+			list = append(list, w)
+			continue
+		}
+		select {
+		case w.events <- e:
+			list = append(list, w)
+		default:
+			// disconnect the slow watcher
+			close(w.events)
+		}
+	}
+	clear(ws.list[len(list):])
+	ws.list = list
+	ws.active.Store(len(list) > 0)
+}
+
+// returns the value of the key with character indices key, or nil
+func (t *SymbolTable) getIndices(key []int) interface{} {
+	x := t.nodeOf(key)
+	if x == nil {
+		return nil
+	}
+	return x.value
+}
+
+func hasPrefix(key, prefix []int) bool {
+	return len(prefix) <= len(key) && slices.Equal(key[:len(prefix)], prefix)
+}
@@ -0,0 +1,102 @@
+package trie_test
+
+import (
+	"sync"
+	"testing"
+
+	"kkn.fi/trie"
+)
+
+func TestSymbolTableWatch(t *testing.T) {
+	st := trie.NewSymbolTable()
+	events, cancel := st.Watch("sh")
+	st.Put("she", 1)
+	st.Put("sea", 2)
+	st.Put("she", 3)
+	st.Swap("shells", 4)
+	st.GetOrPut("shells", 5)
+	st.Update("shells", func(old interface{}, exists bool) (interface{}, bool) {
+		return old.(int) + 1, true
+	})
+	st.Delete("she")
+	st.Delete("shore")
+	st.PutBytes([]byte("shore"), 6)
+	expected := []trie.Event{
+		{Type: trie.EventPut, Key: "she", New: 1},
+		{Type: trie.EventPut, Key: "she", Old: 1, New: 3},
+		{Type: trie.EventPut, Key: "shells", New: 4},
+		{Type: trie.EventPut, Key: "shells", Old: 4, New: 5},
+		{Type: trie.EventDelete, Key: "she", Old: 3},
+		{Type: trie.EventPut, Key: "shore", New: 6},
+	}
+	for _, e := range expected {
+		if got := <-events; got != e {
+			t.Errorf("expected %+v, but got %+v", e, got)
+		}
+	}
+	cancel()
+	cancel()
+	st.Put("shell", 7)
+	if e, ok := <-events; ok {
+		t.Errorf("expected channel to be closed, but got %+v", e)
+	}
+}
+
+func TestSymbolTableWatchSlowConsumer(t *testing.T) {
+	st := trie.NewSymbolTable()
+	slow, cancelSlow := st.Watch("")
+	defer cancelSlow()
+	fast, cancelFast := st.Watch("s")
+	defer cancelFast()
+	for i := 0; i <= trie.WatchBuffer; i++ {
+		st.Put("sea", i)
+		if e := <-fast; e.New != i {
+			t.Errorf("expected value %d, but got %v", i, e.New)
+		}
+	}
+	n := 0
+	for range slow {
+		n++
+	}
+	if n != trie.WatchBuffer {
+		t.Errorf("expected %d events before disconnect, but got %d", trie.WatchBuffer, n)
+	}
+	snapshot := st.Snapshot()
+	snapshot.Put("sea", -1)
+	st.Put("sea", -2)
+	if e := <-fast; e.New != -2 {
+		t.Errorf("expected value -2, but got %v", e.New)
+	}
+}
+
+func TestSymbolTableWatchConcurrently(t *testing.T) {
+	st, err := trie.BuildSymbolTableFromSorted(func(yield func(string, interface{}) bool) {
+		yield("sea", 1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, cancel := st.Watch("s")
+				cancel()
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		st.Put("she", i)
+	}
+	wg.Wait()
+	for _, s := range []*trie.SymbolTable{st.Snapshot(), st.Clone()} {
+		events, cancel := s.Watch("")
+		s.Put("by", 1)
+		if e := <-events; e.Key != "by" {
+			t.Errorf("expected event of 'by', but got %+v", e)
+		}
+		cancel()
+	}
+}